@@ -3,6 +3,9 @@ extends Control
 var folders: Array = []
 var current_folder: String = ""
 var user_presets: Dictionary = {}
+var all_presets: Array = []
+var active_preset: Dictionary = {}
+var param_values: Dictionary = {}
 
 # Colors
 const MAIN_BG = Color("051315")
@@ -16,20 +19,6 @@ const BORDER = Color("1a3a3e")
 const SURFACE = Color("0c1e22")
 const HOVER = Color("122a2f")
 
-const PRESET_ORDER = ["Compress", "WebP", "MP4", "MP3", "Resize 50%", "Resize 25%", "PNG", "JPG", "Optimize", "GIF"]
-const DEFAULT_PRESETS = {
-	"Compress": "convert {} -strip -quality 75 {}",
-	"WebP": "convert {} -quality 90 {dir}/{name}.webp && rm {}",
-	"PNG": "convert {} {dir}/{name}.png && rm {}",
-	"JPG": "convert {} -quality 85 {dir}/{name}.jpg && rm {}",
-	"Optimize": "pngquant --force --quality=65-80 --output {} {}",
-	"Resize 50%": "convert {} -resize 50% {}",
-	"Resize 25%": "convert {} -resize 25% {}",
-	"MP4": "ffmpeg -i {} -c:v libx264 -c:a aac -y {dir}/{name}.mp4 && rm {}",
-	"MP3": "ffmpeg -i {} -c:a libmp3lame -q:a 2 -y {dir}/{name}.mp3 && rm {}",
-	"GIF": "ffmpeg -i {} -vf 'fps=10,scale=480:-1' -y {dir}/{name}.gif && rm {}",
-}
-
 # Nodes
 @onready var hsplit = $HSplit
 @onready var folder_list = $HSplit/SidebarPanel/SidebarMargin/SidebarVBox/FolderScroll/FolderList
@@ -52,6 +41,7 @@ const DEFAULT_PRESETS = {
 @onready var command_input = $HSplit/ContentPanel/ContentMargin/ContentVBox/ConfigPanel/AddSection/CommandInput
 @onready var presets_label = $HSplit/ContentPanel/ContentMargin/ContentVBox/ConfigPanel/AddSection/PresetsLabel
 @onready var presets_flow = $HSplit/ContentPanel/ContentMargin/ContentVBox/ConfigPanel/AddSection/PresetsFlow
+@onready var params_grid = $HSplit/ContentPanel/ContentMargin/ContentVBox/ConfigPanel/AddSection/ParamsGrid
 @onready var save_preset_btn = $HSplit/ContentPanel/ContentMargin/ContentVBox/ConfigPanel/AddSection/ButtonRow/SavePresetBtn
 @onready var manage_presets_btn = $HSplit/ContentPanel/ContentMargin/ContentVBox/ConfigPanel/AddSection/ButtonRow/ManagePresetsBtn
 @onready var add_btn = $HSplit/ContentPanel/ContentMargin/ContentVBox/ConfigPanel/AddSection/ButtonRow/AddBtn
@@ -259,6 +249,7 @@ func _connect_signals():
 	save_preset_dialog.confirmed.connect(_on_save_preset_confirmed)
 	confirm_dialog.confirmed.connect(_on_confirm_action)
 	command_input.text_submitted.connect(func(_t): _on_add_command())
+	command_input.text_changed.connect(func(_t): _clear_params())
 
 func _get_presets_path() -> String:
 	return OS.get_environment("HOME") + "/.config/gato/presets.json"
@@ -274,6 +265,14 @@ func _load_user_presets():
 				user_presets = data["presets"]
 		file.close()
 
+	# Builtin and user presets, with their params, as the CLI sees them
+	all_presets.clear()
+	var output = []
+	if OS.execute("gato", ["preset", "ls", "--json"], output, true) == 0 and output.size() > 0:
+		var json = JSON.new()
+		if json.parse(output[0]) == OK and json.get_data() is Array:
+			all_presets = json.get_data()
+
 func _save_user_presets():
 	var dir = _get_presets_path().get_base_dir()
 	DirAccess.make_dir_recursive_absolute(dir)
@@ -286,45 +285,34 @@ func _setup_presets():
 	for child in presets_flow.get_children():
 		child.queue_free()
 
-	for preset_name in PRESET_ORDER:
+	for preset in all_presets:
 		var btn = Button.new()
-		btn.text = preset_name
+		btn.text = preset.get("name", "")
+		btn.tooltip_text = preset.get("description", preset.get("command", ""))
 		btn.add_theme_font_size_override("font_size", 10)
-		_style_button(btn, "preset")
-		btn.pressed.connect(_on_preset_pressed.bind(preset_name, false))
-		presets_flow.add_child(btn)
-
-	for preset_name in user_presets.keys():
-		var btn = Button.new()
-		btn.text = preset_name
-		btn.add_theme_font_size_override("font_size", 10)
-		_style_button(btn, "user_preset")
-		btn.pressed.connect(_on_preset_pressed.bind(preset_name, true))
+		_style_button(btn, "preset" if preset.get("builtin", false) else "user_preset")
+		btn.pressed.connect(_on_preset_pressed.bind(preset))
 		presets_flow.add_child(btn)
 
 func load_folders():
 	folders.clear()
 	var output = []
-	var exit_code = OS.execute("gato", ["f", "ls", "--raw"], output, true)
+	var exit_code = OS.execute("gato", ["f", "ls", "--json"], output, true)
 
 	if exit_code != 0 or output.size() == 0:
 		return
 
-	var folder_map = {}
-	for line in output[0].split("\n"):
-		if line.strip_edges() == "":
-			continue
-		var parts = line.split("\t")
-		var path = parts[0]
-		var cmd = parts[1] if parts.size() > 1 else ""
-
-		if not folder_map.has(path):
-			folder_map[path] = []
-		if cmd != "":
-			folder_map[path].append({"command": cmd})
+	var json = JSON.new()
+	if json.parse(output[0]) != OK or not json.get_data() is Dictionary:
+		return
 
-	for path in folder_map:
-		folders.append({"path": path, "actions": folder_map[path]})
+	for folder in json.get_data().get("folders", []):
+		var actions = []
+		for action in folder.get("actions", []):
+			# Entries without action or command only register the folder
+			if action.get("command", "") != "" or action.get("action", "") != "":
+				actions.append(action)
+		folders.append({"path": folder["path"], "actions": actions})
 
 func show_welcome():
 	current_folder = ""
@@ -421,10 +409,13 @@ func _refresh_commands_list():
 		return
 
 	for action in actions:
-		var cmd: String = action.get("command", action.get("action", ""))
-		commands_list.add_child(_create_command_item(cmd))
+		commands_list.add_child(_create_command_item(action))
+
+func _create_command_item(action: Dictionary) -> Control:
+	var cmd: String = action.get("command", "")
+	if cmd == "":
+		cmd = action.get("action", "")
 
-func _create_command_item(cmd: String) -> Control:
 	var panel = PanelContainer.new()
 	var style = StyleBoxFlat.new()
 	style.bg_color = SURFACE
@@ -453,7 +444,7 @@ func _create_command_item(cmd: String) -> Control:
 	remove_btn.text = "x"
 	remove_btn.add_theme_font_size_override("font_size", 12)
 	_style_button(remove_btn, "ghost")
-	remove_btn.pressed.connect(_on_remove_command.bind(cmd))
+	remove_btn.pressed.connect(_on_remove_command.bind(action))
 	hbox.add_child(remove_btn)
 
 	return panel
@@ -483,11 +474,71 @@ func _on_open_folder_pressed():
 	if current_folder != "":
 		OS.shell_open(current_folder)
 
-func _on_preset_pressed(preset_name: String, is_user_preset: bool):
-	if is_user_preset:
-		command_input.text = user_presets.get(preset_name, "")
-	else:
-		command_input.text = DEFAULT_PRESETS.get(preset_name, "")
+func _on_preset_pressed(preset: Dictionary):
+	active_preset = preset
+	param_values.clear()
+	for param in preset.get("params", []):
+		param_values[param["name"]] = param.get("default", "")
+	_build_param_fields()
+	_apply_params()
+
+# Renders one form field per preset param; the command is re-expanded on every change
+func _build_param_fields():
+	for child in params_grid.get_children():
+		child.queue_free()
+
+	var params: Array = active_preset.get("params", [])
+	params_grid.visible = params.size() > 0
+
+	for param in params:
+		var pname: String = param["name"]
+		var label = Label.new()
+		label.text = pname
+		label.add_theme_color_override("font_color", DIM)
+		label.add_theme_font_size_override("font_size", 11)
+		params_grid.add_child(label)
+
+		match param.get("type", "string"):
+			"int":
+				var spin = SpinBox.new()
+				spin.min_value = param.get("min", -1000000)
+				spin.max_value = param.get("max", 1000000)
+				spin.value = int(param_values[pname]) if param_values[pname] != "" else spin.min_value
+				spin.value_changed.connect(func(v): _on_param_changed(pname, str(int(v))))
+				params_grid.add_child(spin)
+			"enum":
+				var option = OptionButton.new()
+				var choices: Array = param.get("choices", [])
+				for choice in choices:
+					option.add_item(choice)
+				option.select(max(choices.find(param_values[pname]), 0))
+				option.item_selected.connect(func(i): _on_param_changed(pname, choices[i]))
+				params_grid.add_child(option)
+			_:
+				var input = LineEdit.new()
+				input.text = param_values[pname]
+				_style_input(input)
+				input.text_changed.connect(func(t): _on_param_changed(pname, t))
+				params_grid.add_child(input)
+
+func _on_param_changed(pname: String, value: String):
+	param_values[pname] = value
+	_apply_params()
+
+func _apply_params():
+	var cmd: String = active_preset.get("command", "")
+	for pname in param_values:
+		cmd = cmd.replace("{%s}" % pname, param_values[pname])
+	command_input.set_block_signals(true)
+	command_input.text = cmd
+	command_input.set_block_signals(false)
+
+func _clear_params():
+	active_preset = {}
+	param_values.clear()
+	for child in params_grid.get_children():
+		child.queue_free()
+	params_grid.visible = false
 
 func _on_add_command():
 	var cmd = command_input.text.strip_edges()
@@ -495,12 +546,13 @@ func _on_add_command():
 		return
 	OS.execute("gato", ["f", "add", current_folder, cmd])
 	command_input.text = ""
+	_clear_params()
 	load_folders()
 	_refresh_commands_list()
 	_refresh_folder_list()
 
-func _on_remove_command(cmd: String):
-	OS.execute("gato", ["f", "rm", current_folder, cmd])
+func _on_remove_command(action: Dictionary):
+	OS.execute("gato", ["f", "rm", "--id", action["id"]])
 	load_folders()
 	_refresh_commands_list()
 	_refresh_folder_list()
@@ -531,6 +583,7 @@ func _on_save_preset_confirmed():
 		return
 	user_presets[pname] = cmd
 	_save_user_presets()
+	_load_user_presets()
 	_setup_presets()
 
 func _on_manage_presets_pressed():
@@ -554,8 +607,9 @@ func _refresh_presets_dialog():
 		empty.add_theme_font_size_override("font_size", 12)
 		presets_list.add_child(empty)
 	else:
-		for preset_name in user_presets.keys():
-			presets_list.add_child(_create_preset_dialog_item(preset_name, user_presets[preset_name], true))
+		for preset in all_presets:
+			if not preset.get("builtin", false):
+				presets_list.add_child(_create_preset_dialog_item(preset["name"], preset["command"], true))
 
 	var spacer = Control.new()
 	spacer.custom_minimum_size = Vector2(0, 16)
@@ -567,8 +621,9 @@ func _refresh_presets_dialog():
 	default_label.add_theme_font_size_override("font_size", 10)
 	presets_list.add_child(default_label)
 
-	for preset_name in PRESET_ORDER:
-		presets_list.add_child(_create_preset_dialog_item(preset_name, DEFAULT_PRESETS[preset_name], false))
+	for preset in all_presets:
+		if preset.get("builtin", false):
+			presets_list.add_child(_create_preset_dialog_item(preset["name"], preset["command"], false))
 
 func _create_preset_dialog_item(preset_name: String, cmd: String, deletable: bool) -> Control:
 	var panel = PanelContainer.new()
@@ -617,5 +672,6 @@ func _create_preset_dialog_item(preset_name: String, cmd: String, deletable: boo
 func _on_delete_preset(preset_name: String):
 	user_presets.erase(preset_name)
 	_save_user_presets()
+	_load_user_presets()
 	_refresh_presets_dialog()
 	_setup_presets()
@@ -2,16 +2,22 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
 	"os/exec"
 
 	"github.com/veinticinco/gato-daemon/internal/folders"
+	"github.com/veinticinco/gato-daemon/internal/notify"
+	"github.com/veinticinco/gato-daemon/internal/rpc"
+	"github.com/veinticinco/gato-daemon/internal/service"
 )
 
 const version = "0.1.0"
@@ -26,8 +32,24 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Log to the journal (stderr) and to a file that notifications can open
+	if logFile, err := openLog(); err != nil {
+		log.Printf("Warning: cannot open log file: %v", err)
+	} else {
+		defer logFile.Close()
+		log.SetOutput(io.MultiWriter(os.Stderr, logFile))
+	}
+
 	log.Printf("Starting gato-daemon v%s", version)
 
+	// The control socket also keeps a second daemon from starting
+	srv, err := rpc.Listen(rpc.SocketPath())
+	if errors.Is(err, rpc.ErrRunning) {
+		log.Fatal(err)
+	} else if err != nil {
+		log.Printf("Warning: control socket unavailable: %v", err)
+	}
+
 	// Run COSMIC setup on first launch (only if not already done)
 	homeDir, _ := os.UserHomeDir()
 	markerFile := homeDir + "/.config/gato-cosmic-setup-done"
@@ -45,7 +67,27 @@ func main() {
 	// Start folder manager
 	mgr := folders.New()
 
+	if n, err := notify.New(); err != nil {
+		log.Printf("Warning: D-Bus notifications unavailable, using notify-send: %v", err)
+	} else {
+		defer n.Close()
+		mgr.SetNotifier(n)
+	}
+
+	// Desktop components talk to the daemon as org.gato.Daemon1
+	if svc, err := service.Connect(mgr, version); err != nil {
+		log.Printf("Warning: D-Bus service unavailable: %v", err)
+	} else {
+		defer svc.Close()
+	}
+
+	mgr.EnableStatus(version)
+	if srv != nil {
+		go mgr.Serve(ctx, srv)
+	}
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		if err := mgr.Start(ctx); err != nil {
 			log.Printf("Folder manager error: %v", err)
 		}
@@ -60,4 +102,17 @@ func main() {
 
 	log.Println("Shutting down...")
 	cancel()
+	<-done
+}
+
+// openLog opens the daemon log for appending, starting over once it grows past 5MB
+func openLog() (*os.File, error) {
+	path := folders.LogPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > 5<<20 {
+		os.Rename(path, path+".1")
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 }
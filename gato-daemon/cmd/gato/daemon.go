@@ -0,0 +1,13 @@
+package main
+
+import "github.com/veinticinco/gato-daemon/internal/rpc"
+
+// dialDaemon connects to a running daemon's control socket, or returns nil
+// if none is running
+func dialDaemon() *rpc.Client {
+	c, err := rpc.Dial(rpc.SocketPath())
+	if err != nil {
+		return nil
+	}
+	return c
+}
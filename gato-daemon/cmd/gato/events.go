@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/veinticinco/gato-daemon/internal/folders"
+)
+
+// errDone ends a subscription after the first event
+var errDone = errors.New("done")
+
+func handleEvents(args []string) {
+	fs := pflag.NewFlagSet("events", pflag.ContinueOnError)
+	follow := fs.BoolP("follow", "f", false, "keep printing events until interrupted")
+	kinds := fs.StringSliceP("kind", "k", nil, "only these kinds of events")
+	parseFlags(fs, args, printEventsHelp)
+
+	for _, k := range *kinds {
+		if !contains(folders.EventKinds, k) {
+			fail(false, fmt.Errorf("unknown event kind: %s (available: %s)", k, strings.Join(folders.EventKinds, ", ")))
+		}
+	}
+
+	c := dialDaemon()
+	if c == nil {
+		fail(false, fmt.Errorf("gato-daemon is not running"))
+	}
+	defer c.Close()
+
+	// Events are printed as the daemon sends them, one JSON object per line
+	err := c.Subscribe("events", map[string]any{"kinds": *kinds}, func(event json.RawMessage) error {
+		if _, err := os.Stdout.Write(append(event, '\n')); err != nil {
+			return err
+		}
+		if !*follow {
+			return errDone
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDone) {
+		fail(false, err)
+	}
+}
+
+func printEventsHelp() {
+	fmt.Println("gato events - Print what the daemon does as JSON lines")
+	fmt.Println()
+	fmt.Println("Usage: gato events [--follow] [--kind <kind>]...")
+	fmt.Println()
+	fmt.Println("Waits for the next event and prints it, or every event with --follow.")
+	fmt.Println()
+	fmt.Println("Flags:")
+	fmt.Println("  -f, --follow        Keep printing events until interrupted")
+	fmt.Println("  -k, --kind <kind>   Only these kinds, repeatable or comma separated:")
+	fmt.Printf("                      %s\n", strings.Join(folders.EventKinds, ", "))
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  gato events --follow")
+	fmt.Println("  gato events -k job-finished | jq -r .job.file")
+}
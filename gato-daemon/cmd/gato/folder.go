@@ -0,0 +1,1278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/veinticinco/gato-daemon/internal/folders"
+	"github.com/veinticinco/gato-daemon/internal/history"
+	"github.com/veinticinco/gato-daemon/internal/originals"
+	"github.com/veinticinco/gato-daemon/internal/presets"
+)
+
+func handleFolder(args []string) {
+	if len(args) == 0 {
+		// Default to list
+		args = []string{"ls"}
+	}
+
+	mgr := folders.New()
+	if args[0] == "check" {
+		cmdCheck(mgr, args[1:])
+		return
+	}
+	if err := mgr.LoadConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add", "a":
+		cmdAdd(mgr, args[1:])
+	case "remove", "rm":
+		cmdRemove(mgr, args[1:])
+	case "list", "ls":
+		cmdList(mgr, args[1:])
+	case "status", "st":
+		cmdStatus(mgr, args[1:])
+	case "edit", "e":
+		cmdEdit(mgr, args[1:])
+	case "move", "mv":
+		cmdMove(mgr, args[1:])
+	case "pause":
+		cmdPause(mgr, args[1:])
+	case "resume":
+		cmdResume(mgr, args[1:])
+	case "enable":
+		cmdEnable(mgr, args[1:], true)
+	case "disable":
+		cmdEnable(mgr, args[1:], false)
+	case "undo":
+		cmdUndo(mgr, args[1:])
+	case "run":
+		cmdRun(args[1:])
+	case "test", "t":
+		cmdTest(mgr, args[1:])
+	case "originals", "orig":
+		cmdOriginals(mgr, args[1:])
+	case "-h", "--help", "help":
+		printFolderHelp()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown subcommand: %s\n\n", args[0])
+		printFolderHelp()
+		os.Exit(1)
+	}
+}
+
+// parseFlags parses args into fs, printing usage on -h and exiting on errors.
+// It returns the positional arguments.
+func parseFlags(fs *pflag.FlagSet, args []string, usage func()) []string {
+	fs.SortFlags = false
+	fs.Usage = usage
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return fs.Args()
+}
+
+// actionFlags select what runs on a file: a predefined action, a custom
+// command or a preset. They are shared by every subcommand that takes one.
+type actionFlags struct {
+	action  string
+	command string
+	preset  string
+	values  []string
+}
+
+func (a *actionFlags) register(fs *pflag.FlagSet) {
+	fs.StringVarP(&a.action, "action", "a", "", "predefined action")
+	fs.StringVarP(&a.command, "command", "c", "", "custom command")
+	fs.StringVarP(&a.preset, "preset", "p", "", "preset command")
+	fs.StringArrayVar(&a.values, "set", nil, "preset param (key=value)")
+}
+
+// resolve returns the action and command to store. A positional command is
+// treated like -c. Returns empty strings when nothing was selected.
+func (a *actionFlags) resolve(positional string) (action, command string, err error) {
+	if positional != "" {
+		if a.command != "" {
+			return "", "", fmt.Errorf("command given twice (positional and -c)")
+		}
+		a.command = positional
+	}
+
+	selected := 0
+	for _, v := range []string{a.action, a.command, a.preset} {
+		if v != "" {
+			selected++
+		}
+	}
+	if selected > 1 {
+		return "", "", fmt.Errorf("use only one of -a, -c and -p")
+	}
+	if len(a.values) > 0 && a.preset == "" {
+		return "", "", fmt.Errorf("--set only applies to presets (-p)")
+	}
+
+	switch {
+	case a.action != "":
+		if !folders.IsPredefinedAction(a.action) {
+			var names []string
+			for _, pa := range folders.PredefinedActions {
+				names = append(names, pa.Name)
+			}
+			return "", "", fmt.Errorf("unknown action: %s (available: %s)", a.action, strings.Join(names, ", "))
+		}
+		return a.action, "", nil
+	case a.preset != "":
+		store := presets.New()
+		if err := store.Load(); err != nil {
+			return "", "", err
+		}
+		p, ok := store.Get(a.preset)
+		if !ok {
+			var names []string
+			for _, p := range store.List() {
+				names = append(names, p.Name)
+			}
+			return "", "", fmt.Errorf("unknown preset: %s (available: %s)", a.preset, strings.Join(names, ", "))
+		}
+		vals, err := presets.ParseValues(a.values)
+		if err != nil {
+			return "", "", err
+		}
+		command, err := p.Expand(vals)
+		if err != nil {
+			return "", "", err
+		}
+		return "", command, nil
+	default:
+		return "", a.command, nil
+	}
+}
+
+func cmdAdd(mgr *folders.Manager, args []string) {
+	fs := pflag.NewFlagSet("add", pflag.ContinueOnError)
+	var sel actionFlags
+	sel.register(fs)
+	extensions := fs.StringSliceP("ext", "e", nil, "only process these extensions")
+	keepOriginal := fs.BoolP("keep", "k", false, "keep originals in .originals/")
+	notifyLevel := fs.StringP("notify", "n", folders.NotifyAlways, "when to notify")
+	asJSON := fs.Bool("json", false, "JSON output")
+	positional := parseFlags(fs, args, printAddHelp)
+
+	if len(positional) == 0 || len(positional) > 2 {
+		printAddHelp()
+		os.Exit(1)
+	}
+
+	path := expandPath(positional[0])
+	var inline string
+	if len(positional) == 2 {
+		inline = positional[1]
+	}
+
+	action, command, err := sel.resolve(inline)
+	if err != nil {
+		fail(*asJSON, err)
+	}
+	if command != "" {
+		if err := presets.ValidatePlaceholders(command); err != nil {
+			fail(*asJSON, err)
+		}
+	}
+
+	var added folders.FolderAction
+	err = mgr.Update(func() (err error) {
+		added, err = mgr.AddFolder(path, action, command, *extensions, *keepOriginal, *notifyLevel)
+		return err
+	})
+	if err != nil {
+		fail(*asJSON, err)
+	}
+	for _, i := range mgr.CheckConfig() {
+		if i.Path == added.Path && (i.ActionID == added.ID || i.ActionID == "") {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", i.Message)
+		}
+	}
+
+	if *asJSON {
+		printJSON(jsonChange{
+			Version: jsonVersion,
+			Folder:  path,
+			Added:   []folders.FolderAction{added},
+			Removed: []folders.FolderAction{},
+		})
+		return
+	}
+
+	name := filepath.Base(path)
+	switch {
+	case command != "":
+		fmt.Printf("Added command to %s:\n  %s\n", name, truncate(command, 50))
+	case action != "":
+		fmt.Printf("Added action to %s:\n  %s\n", name, action)
+	default:
+		fmt.Printf("Added folder: %s\n", name)
+	}
+}
+
+func cmdRemove(mgr *folders.Manager, args []string) {
+	fs := pflag.NewFlagSet("rm", pflag.ContinueOnError)
+	var sel actionFlags
+	sel.register(fs)
+	id := fs.String("id", "", "remove the action with this ID")
+	asJSON := fs.Bool("json", false, "JSON output")
+	positional := parseFlags(fs, args, printRemoveHelp)
+
+	if *id != "" {
+		if len(positional) > 0 || sel.action != "" || sel.command != "" || sel.preset != "" {
+			fail(*asJSON, fmt.Errorf("--id cannot be combined with a path, action or command"))
+		}
+		var removed folders.FolderAction
+		err := mgr.Update(func() (err error) {
+			removed, err = mgr.RemoveActionByID(*id)
+			return err
+		})
+		if err != nil {
+			fail(*asJSON, err)
+		}
+		if *asJSON {
+			printJSON(jsonChange{
+				Version: jsonVersion,
+				Folder:  removed.Path,
+				Added:   []folders.FolderAction{},
+				Removed: []folders.FolderAction{removed},
+			})
+			return
+		}
+		fmt.Printf("Removed %s from %s:\n  %s\n", removed.ID, filepath.Base(removed.Path), truncate(describe(removed), 50))
+		return
+	}
+
+	if len(positional) == 0 || len(positional) > 2 {
+		printRemoveHelp()
+		os.Exit(1)
+	}
+
+	path := expandPath(positional[0])
+	name := filepath.Base(path)
+	var inline string
+	if len(positional) == 2 {
+		inline = positional[1]
+	}
+
+	action, command, err := sel.resolve(inline)
+	if err != nil {
+		fail(*asJSON, err)
+	}
+
+	// Without an action or command the whole folder goes
+	var removed []folders.FolderAction
+	err = mgr.Update(func() (err error) {
+		if action == "" && command == "" {
+			removed, err = mgr.RemoveFolder(path)
+			return err
+		}
+		f, err := mgr.RemoveAction(path, action, command)
+		removed = []folders.FolderAction{f}
+		return err
+	})
+	if err != nil {
+		fail(*asJSON, err)
+	}
+
+	switch {
+	case *asJSON:
+		printJSON(jsonChange{
+			Version: jsonVersion,
+			Folder:  path,
+			Added:   []folders.FolderAction{},
+			Removed: removed,
+		})
+	case command != "":
+		fmt.Printf("Removed command from %s:\n  %s\n", name, truncate(command, 50))
+	case action != "":
+		fmt.Printf("Removed action from %s:\n  %s\n", name, action)
+	default:
+		fmt.Printf("Removed folder: %s\n", name)
+	}
+}
+
+func cmdList(mgr *folders.Manager, args []string) {
+	fs := pflag.NewFlagSet("ls", pflag.ContinueOnError)
+	raw := fs.Bool("raw", false, "tab-separated output")
+	asJSON := fs.Bool("json", false, "JSON output")
+	positional := parseFlags(fs, args, printListHelp)
+
+	var specificPath string
+	if len(positional) > 0 {
+		specificPath = expandPath(positional[0])
+	}
+
+	paths := mgr.ListUniqueFolders()
+
+	if *asJSON {
+		out := jsonList{Version: jsonVersion, Folders: []jsonFolder{}}
+		for _, path := range paths {
+			if specificPath == "" || path == specificPath {
+				out.Folders = append(out.Folders, folderJSON(mgr, path))
+			}
+		}
+		if specificPath != "" && len(out.Folders) == 0 {
+			fail(true, fmt.Errorf("folder not found: %s", specificPath))
+		}
+		printJSON(out)
+		return
+	}
+
+	// If specific path provided, show only that folder's commands
+	if specificPath != "" {
+		actions := mgr.GetFolderActions(specificPath)
+		if len(actions) == 0 {
+			// Check if folder exists but has no commands
+			found := false
+			for _, p := range paths {
+				if p == specificPath {
+					found = true
+					break
+				}
+			}
+			if !found {
+				fmt.Fprintf(os.Stderr, "Folder not found: %s\n", specificPath)
+				os.Exit(1)
+			}
+			fmt.Printf("%s: no commands\n", filepath.Base(specificPath))
+			return
+		}
+
+		if *raw {
+			for _, a := range actions {
+				fmt.Printf("%s\t%s\n", specificPath, describe(a))
+			}
+		} else {
+			fmt.Printf("%s (%d commands)%s:\n", filepath.Base(specificPath), len(actions), pausedLabel(mgr, specificPath))
+			for _, a := range actions {
+				fmt.Printf("  %s  %s%s  (notify: %s)\n", a.ID, describe(a), disabledLabel(a), a.NotifyMode())
+			}
+		}
+		return
+	}
+
+	// List all folders
+	if *raw {
+		for _, path := range paths {
+			actions := mgr.GetFolderActions(path)
+			for _, a := range actions {
+				fmt.Printf("%s\t%s\n", path, describe(a))
+			}
+		}
+		return
+	}
+
+	if len(paths) == 0 {
+		fmt.Println("No intelligent folders configured.")
+		fmt.Println()
+		fmt.Println("Add one:")
+		fmt.Println("  gato f add ~/Photos -p compress")
+		fmt.Println("  gato f add ~/Videos -a convert-mp4")
+		fmt.Println("  gato f add ~/Downloads \"convert {} -resize 50% {}\"")
+		return
+	}
+
+	for _, path := range paths {
+		actions := mgr.GetFolderActions(path)
+		fmt.Printf("%s (%d)%s\n", filepath.Base(path), len(actions), pausedLabel(mgr, path))
+		for _, a := range actions {
+			fmt.Printf("  %s  %s%s\n", a.ID, truncate(describe(a), 60), disabledLabel(a))
+		}
+	}
+}
+
+func cmdStatus(mgr *folders.Manager, args []string) {
+	fs := pflag.NewFlagSet("status", pflag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "JSON output")
+	positional := parseFlags(fs, args, printStatusHelp)
+
+	var specificPath string
+	if len(positional) > 0 {
+		specificPath = expandPath(positional[0])
+	}
+
+	out := jsonStatus{Version: jsonVersion, Folders: []jsonFolderStatus{}}
+	for _, path := range mgr.ListUniqueFolders() {
+		if specificPath != "" && path != specificPath {
+			continue
+		}
+		st := jsonFolderStatus{Path: path, State: "ok", Actions: len(mgr.GetFolderActions(path))}
+		info, err := os.Stat(path)
+		switch {
+		case !mgr.FolderEnabled(path):
+			st.State = "paused"
+		case os.IsNotExist(err):
+			st.State = "missing"
+		case err != nil:
+			st.State = "error"
+			st.Error = err.Error()
+		case !info.IsDir():
+			st.State = "not-a-directory"
+		}
+		out.Folders = append(out.Folders, st)
+	}
+	if specificPath != "" && len(out.Folders) == 0 {
+		fail(*asJSON, fmt.Errorf("folder not found: %s", specificPath))
+	}
+
+	if *asJSON {
+		printJSON(out)
+		return
+	}
+
+	if len(out.Folders) == 0 {
+		fmt.Println("No intelligent folders configured.")
+		return
+	}
+	for _, st := range out.Folders {
+		state := st.State
+		if st.Error != "" {
+			state += ": " + st.Error
+		}
+		fmt.Printf("%-20s %-16s %d actions  %s\n", filepath.Base(st.Path), state, st.Actions, st.Path)
+	}
+}
+
+func cmdEdit(mgr *folders.Manager, args []string) {
+	fs := pflag.NewFlagSet("edit", pflag.ContinueOnError)
+	var sel actionFlags
+	sel.register(fs)
+	extensions := fs.StringSliceP("ext", "e", nil, "only process these extensions")
+	keep := fs.BoolP("keep", "k", false, "keep originals in .originals/")
+	noKeep := fs.Bool("no-keep", false, "stop keeping originals")
+	notifyLevel := fs.StringP("notify", "n", "", "when to notify")
+	asJSON := fs.Bool("json", false, "JSON output")
+	positional := parseFlags(fs, args, printEditHelp)
+
+	if len(positional) != 1 {
+		printEditHelp()
+		os.Exit(1)
+	}
+	if *keep && *noKeep {
+		fail(*asJSON, fmt.Errorf("use only one of --keep and --no-keep"))
+	}
+	if *notifyLevel != "" {
+		if err := folders.ValidateNotifyLevel(*notifyLevel); err != nil {
+			fail(*asJSON, err)
+		}
+	}
+
+	action, command, err := sel.resolve("")
+	if err != nil {
+		fail(*asJSON, err)
+	}
+	if command != "" {
+		if err := presets.ValidatePlaceholders(command); err != nil {
+			fail(*asJSON, err)
+		}
+		if missing := presets.MissingBinaries(command); len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: not installed: %s\n", strings.Join(missing, ", "))
+		}
+	}
+
+	before, err := mgr.GetAction(positional[0])
+	if err != nil {
+		fail(*asJSON, err)
+	}
+	var after folders.FolderAction
+	err = mgr.Update(func() (err error) {
+		after, err = mgr.UpdateAction(before.ID, func(f *folders.FolderAction) {
+			if action != "" || command != "" {
+				f.Action, f.Command = action, command
+			}
+			if fs.Changed("ext") {
+				f.Extensions = *extensions
+			}
+			if *keep {
+				f.KeepOriginal = true
+			}
+			if *noKeep {
+				f.KeepOriginal = false
+			}
+			if *notifyLevel != "" {
+				f.NotifyLevel = *notifyLevel
+				f.Notify = *notifyLevel != folders.NotifyNever
+			}
+		})
+		return err
+	})
+	if err != nil {
+		fail(*asJSON, err)
+	}
+
+	if *asJSON {
+		printJSON(jsonChange{
+			Version: jsonVersion,
+			Folder:  after.Path,
+			Added:   []folders.FolderAction{after},
+			Removed: []folders.FolderAction{before},
+		})
+		return
+	}
+	fmt.Printf("Updated %s in %s:\n  %s\n", after.ID, filepath.Base(after.Path), truncate(describe(after), 50))
+}
+
+func cmdMove(mgr *folders.Manager, args []string) {
+	fs := pflag.NewFlagSet("move", pflag.ContinueOnError)
+	before := fs.String("before", "", "run before this action")
+	after := fs.String("after", "", "run after this action")
+	positional := parseFlags(fs, args, printMoveHelp)
+
+	if len(positional) != 1 || (*before == "") == (*after == "") {
+		printMoveHelp()
+		os.Exit(1)
+	}
+
+	target := *before
+	if *after != "" {
+		target = *after
+	}
+	if err := mgr.Update(func() error { return mgr.MoveAction(positional[0], target, *after != "") }); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	f, _ := mgr.GetAction(positional[0])
+	fmt.Printf("%s (%d):\n", filepath.Base(f.Path), len(mgr.GetFolderActions(f.Path)))
+	for _, a := range mgr.GetFolderActions(f.Path) {
+		fmt.Printf("  %s  %s\n", a.ID, truncate(describe(a), 60))
+	}
+}
+
+func cmdPause(mgr *folders.Manager, args []string) {
+	fs := pflag.NewFlagSet("pause", pflag.ContinueOnError)
+	duration := fs.Duration("for", 0, "resume automatically after this long (e.g. 2h, 30m)")
+	positional := parseFlags(fs, args, printPauseHelp)
+
+	if len(positional) != 1 {
+		printPauseHelp()
+		os.Exit(1)
+	}
+
+	path := expandPath(positional[0])
+	var until time.Time
+	if *duration > 0 {
+		until = time.Now().Add(*duration).Truncate(time.Second)
+	}
+	if err := pauseFolder(mgr, path, until); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if until.IsZero() {
+		fmt.Printf("Paused %s (gato f resume %s to continue)\n", filepath.Base(path), positional[0])
+	} else {
+		fmt.Printf("Paused %s until %s\n", filepath.Base(path), until.Format("15:04"))
+	}
+}
+
+func cmdResume(mgr *folders.Manager, args []string) {
+	positional := parseFlags(pflag.NewFlagSet("resume", pflag.ContinueOnError), args, printPauseHelp)
+	if len(positional) != 1 {
+		printPauseHelp()
+		os.Exit(1)
+	}
+
+	path := expandPath(positional[0])
+	if err := resumeFolder(mgr, path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Resumed %s\n", filepath.Base(path))
+}
+
+func cmdEnable(mgr *folders.Manager, args []string, enabled bool) {
+	positional := parseFlags(pflag.NewFlagSet("enable", pflag.ContinueOnError), args, printPauseHelp)
+	if len(positional) != 1 {
+		printPauseHelp()
+		os.Exit(1)
+	}
+
+	var f folders.FolderAction
+	err := mgr.Update(func() (err error) {
+		f, err = mgr.SetActionEnabled(positional[0], enabled)
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	state := "Enabled"
+	if !enabled {
+		state = "Disabled"
+	}
+	fmt.Printf("%s %s in %s:\n  %s\n", state, f.ID, filepath.Base(f.Path), truncate(describe(f), 50))
+}
+
+func cmdUndo(mgr *folders.Manager, args []string) {
+	fs := pflag.NewFlagSet("undo", pflag.ContinueOnError)
+	last := fs.IntP("last", "l", 0, "undo the last N processed files")
+	positional := parseFlags(fs, args, printUndoHelp)
+
+	if len(positional) > 1 || (len(positional) == 1 && *last > 0) {
+		printUndoHelp()
+		os.Exit(1)
+	}
+
+	var undone []history.Job
+	var err error
+	if len(positional) == 1 {
+		undone, err = mgr.UndoFile(expandPath(positional[0]))
+	} else {
+		if *last <= 0 {
+			*last = 1
+		}
+		undone, err = mgr.UndoLast(*last)
+	}
+
+	seen := make(map[string]bool)
+	for _, j := range undone {
+		if !seen[j.Run] {
+			seen[j.Run] = true
+			fmt.Printf("Undone: %s\n", j.File)
+		}
+		for _, out := range j.Outputs {
+			fmt.Printf("  removed %s\n", filepath.Base(out))
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func cmdOriginals(mgr *folders.Manager, args []string) {
+	fs := pflag.NewFlagSet("originals", pflag.ContinueOnError)
+	maxAge := fs.String("max-age", "", "drop versions older than this (e.g. 30d, 0 = forever)")
+	maxSize := fs.String("max-size", "", "cap the store at this size (e.g. 2G, 0 = no limit)")
+	prune := fs.Bool("prune", false, "apply the retention policy now")
+	positional := parseFlags(fs, args, printOriginalsHelp)
+
+	if len(positional) != 1 {
+		printOriginalsHelp()
+		os.Exit(1)
+	}
+
+	path := expandPath(positional[0])
+	if *maxAge != "" || *maxSize != "" {
+		if err := mgr.Update(func() error { return mgr.SetRetention(path, *maxAge, *maxSize) }); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	store, err := mgr.Originals(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *prune || *maxAge != "" || *maxSize != "" {
+		removed, freed, err := store.Prune()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Pruned %d %s, freed %s\n", removed, plural(removed, "version", "versions"), originals.FormatSize(freed))
+		return
+	}
+
+	versions, err := store.Versions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(versions) == 0 {
+		fmt.Println("No originals kept")
+		return
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		imported := ""
+		if v.Migrated {
+			imported = "  (imported)"
+		}
+		fmt.Printf("%s  %7s  %s  %s%s\n", v.Time.Local().Format("2006-01-02 15:04"), originals.FormatSize(v.Size), v.Hash[:12], v.Name, imported)
+	}
+
+	s := mgr.FolderSettings(path)
+	fmt.Printf("\nKeeping versions for %s, up to %s\n", orDefault(s.OriginalsMaxAge, "30d"), orDefault(s.OriginalsMaxSize, "no limit"))
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+func orDefault(s, def string) string {
+	if s == "" || s == "0" {
+		return def
+	}
+	return s
+}
+
+// pauseFolder asks a running daemon to pause the folder so it stops right
+// away, or edits the config directly
+func pauseFolder(mgr *folders.Manager, path string, until time.Time) error {
+	if c := dialDaemon(); c != nil {
+		defer c.Close()
+		return c.Call("pause", map[string]any{"path": path, "until": until}, nil)
+	}
+	return mgr.Update(func() error { return mgr.PauseFolder(path, until) })
+}
+
+func resumeFolder(mgr *folders.Manager, path string) error {
+	if c := dialDaemon(); c != nil {
+		defer c.Close()
+		return c.Call("resume", map[string]any{"path": path}, nil)
+	}
+	return mgr.Update(func() error { return mgr.ResumeFolder(path) })
+}
+
+// pausedLabel returns " paused" or " paused until 15:04" for listings
+func pausedLabel(mgr *folders.Manager, path string) string {
+	if mgr.FolderEnabled(path) {
+		return ""
+	}
+	if s := mgr.FolderSettings(path); !s.PausedUntil.IsZero() {
+		return " paused until " + s.PausedUntil.Local().Format("15:04")
+	}
+	return " paused"
+}
+
+func disabledLabel(a folders.FolderAction) string {
+	if a.IsEnabled() {
+		return ""
+	}
+	return "  (disabled)"
+}
+
+// describe returns the command of an action, or its predefined action name
+func describe(a folders.FolderAction) string {
+	if a.Command != "" {
+		return a.Command
+	}
+	return a.Action
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max-3] + "..."
+	}
+	return s
+}
+
+func printFolderHelp() {
+	fmt.Println("gato folder - Manage intelligent folders")
+	fmt.Println()
+	fmt.Println("Usage: gato f <command> [args]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  ls, list     List folders (default)")
+	fmt.Println("  add, a       Add folder or command")
+	fmt.Println("  rm, remove   Remove folder or command")
+	fmt.Println("  status, st   Check that folders exist and can be watched")
+	fmt.Println("  edit, e      Change an action by ID")
+	fmt.Println("  move, mv     Reorder an action by ID")
+	fmt.Println("  pause        Stop processing a folder, keeping its actions")
+	fmt.Println("  resume       Resume a paused folder")
+	fmt.Println("  disable      Turn off a single action by ID")
+	fmt.Println("  enable       Turn a disabled action back on")
+	fmt.Println("  undo         Restore originals and remove outputs of processed files")
+	fmt.Println("  run          Process files already in a folder")
+	fmt.Println("  test, t      Try a folder's actions or a command on a copy of a file")
+	fmt.Println("  check        Look for problems in the config")
+	fmt.Println("  originals    List kept originals and set how long they are kept")
+	fmt.Println()
+	fmt.Println("ls, add, rm and status accept --json for machine-readable output.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  gato f ls                              List all folders")
+	fmt.Println("  gato f ls ~/Photos                     Show folder's commands")
+	fmt.Println("  gato f add ~/Photos                    Add empty folder")
+	fmt.Println("  gato f add ~/Photos -p compress        Add folder with preset")
+	fmt.Println("  gato f add ~/Videos -a convert-mp4     Add predefined action")
+	fmt.Println("  gato f add ~/Photos -c \"convert {} ...\" Add custom command")
+	fmt.Println("  gato f rm ~/Photos                     Remove folder entirely")
+	fmt.Println("  gato f rm ~/Photos -c \"convert {} ...\"  Remove specific command")
+	fmt.Println("  gato f rm --id 3fa2c1                  Remove action by ID")
+	fmt.Println("  gato f edit 3fa2c1 -e png,jpg          Change an action's extensions")
+	fmt.Println("  gato f move 3fa2c1 --before 9b0d4e     Run an action earlier")
+	fmt.Println("  gato f pause ~/Photos --for 2h         Pause a folder for two hours")
+	fmt.Println("  gato f undo --last 3                   Undo the last three processed files")
+	fmt.Println("  gato f run ~/Photos -m '*.png'         Process existing PNGs")
+	fmt.Println("  gato f test webp ~/Desktop/shot.png    See what a preset would do")
+	fmt.Println("  gato f check                           Look for config problems")
+}
+
+// printSelectFlags documents the flags registered by actionFlags
+func printSelectFlags() {
+	fmt.Println("  -a, --action <name>   Use a predefined action")
+	fmt.Println("  -c, --command <cmd>   Use a custom command (same as the positional <command>)")
+	fmt.Println("  -p, --preset <name>   Use a preset command")
+	fmt.Println("  --set <key=value>     Set a preset param (repeatable)")
+}
+
+func printAddHelp() {
+	fmt.Println("gato folder add - Add folder or command")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  gato f add <path>                      Add empty folder")
+	fmt.Println("  gato f add <path> <command>            Add command to folder")
+	fmt.Println("  gato f add <path> -a <action>          Add predefined action")
+	fmt.Println("  gato f add <path> -p <preset>          Add preset command")
+	fmt.Println()
+	fmt.Println("Flags:")
+	printSelectFlags()
+	fmt.Println("  -e, --ext <list>      Only process these extensions (comma-separated)")
+	fmt.Println("  -k, --keep            Keep originals in .originals/")
+	fmt.Println("  -n, --notify <level>  never, failure, always (default) or summary")
+	fmt.Println("  --json                Print the added action as JSON")
+	fmt.Println()
+	fmt.Println("Actions:")
+	for _, a := range folders.PredefinedActions {
+		fmt.Printf("  %-13s %s\n", a.Name, a.Description)
+	}
+	fmt.Println()
+	fmt.Println("Presets:")
+	for _, p := range presets.Builtins() {
+		fmt.Printf("  %-13s %s%s\n", p.Name, p.Description, formatParams(p.Params))
+	}
+	fmt.Println("  (plus user presets, see gato preset ls)")
+	fmt.Println()
+	fmt.Println("Command placeholders:")
+	fmt.Println("  {}          Full file path")
+	fmt.Println("  {name}      Filename without extension")
+	fmt.Println("  {ext}       File extension")
+	fmt.Println("  {dir}       Directory path")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  gato f add ~/Photos -a compress -k")
+	fmt.Println("  gato f add ~/Screenshots -p webp -e png,jpg")
+	fmt.Println("  gato f add ~/Camera -a compress -n summary")
+	fmt.Println("  gato f add ~/Resize -p resize --set percent=33")
+	fmt.Println("  gato f add ~/Videos -c \"ffmpeg -i {} -crf 28 {dir}/{name}_small.mp4\"")
+}
+
+func printRemoveHelp() {
+	fmt.Println("gato folder rm - Remove folder or command")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  gato f rm <path>                       Remove folder entirely")
+	fmt.Println("  gato f rm <path> <command>             Remove specific command")
+	fmt.Println("  gato f rm <path> -a <action>           Remove predefined action")
+	fmt.Println("  gato f rm <path> -p <preset>           Remove the command a preset added")
+	fmt.Println("  gato f rm --id <id>                    Remove action by ID (see gato f ls)")
+	fmt.Println()
+	fmt.Println("Flags:")
+	printSelectFlags()
+	fmt.Println("  --id <id>             Action ID or unique prefix")
+	fmt.Println("  --json                Print the removed actions as JSON")
+}
+
+func printListHelp() {
+	fmt.Println("gato folder ls - List folders")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  gato f ls [path]")
+	fmt.Println()
+	fmt.Println("Flags:")
+	fmt.Println("  --raw                 Tab-separated output (path, command)")
+	fmt.Println("  --json                JSON output with every action field")
+}
+
+func printEditHelp() {
+	fmt.Println("gato folder edit - Change an action")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  gato f edit <id> [flags]")
+	fmt.Println()
+	fmt.Println("Flags:")
+	printSelectFlags()
+	fmt.Println("  -e, --ext <list>      Only process these extensions (empty = all)")
+	fmt.Println("  -k, --keep            Keep originals in .originals/")
+	fmt.Println("  --no-keep             Stop keeping originals")
+	fmt.Println("  -n, --notify <level>  never, failure, always or summary")
+	fmt.Println("  --json                Print the old and new action as JSON")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  gato f edit 3fa2c1 -c \"convert {} -resize 40% {}\"")
+	fmt.Println("  gato f edit 3fa2c1 -e \"\" --no-keep")
+}
+
+func printMoveHelp() {
+	fmt.Println("gato folder move - Reorder actions within a folder")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  gato f move <id> --before <id>")
+	fmt.Println("  gato f move <id> --after <id>")
+	fmt.Println()
+	fmt.Println("Actions run on each new file in the order they are listed.")
+}
+
+func printPauseHelp() {
+	fmt.Println("gato folder pause/resume/enable/disable - Turn folders and actions off without removing them")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  gato f pause <path> [--for <duration>]  Stop processing a folder")
+	fmt.Println("  gato f resume <path>                    Resume a paused folder")
+	fmt.Println("  gato f disable <id>                     Turn off one action")
+	fmt.Println("  gato f enable <id>                      Turn an action back on")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  gato f pause ~/Photos --for 2h")
+	fmt.Println("  gato f disable 3fa2c1")
+}
+
+// cmdRun has the daemon queue the files already in a folder
+func cmdRun(args []string) {
+	fs := pflag.NewFlagSet("run", pflag.ContinueOnError)
+	match := fs.StringP("match", "m", "", "only file names matching this glob")
+	since := fs.String("since", "", "only files modified after this (e.g. 2h, 7d, 2026-10-01)")
+	dryRun := fs.BoolP("dry-run", "n", false, "list the files without processing them")
+	asJSON := fs.Bool("json", false, "JSON output")
+	positional := parseFlags(fs, args, printRunHelp)
+
+	if len(positional) != 1 {
+		printRunHelp()
+		os.Exit(1)
+	}
+	opts := folders.RunOptions{Match: *match, DryRun: *dryRun}
+	var err error
+	if opts.Since, err = parseTime(*since); err != nil {
+		fail(*asJSON, err)
+	}
+	path := expandPath(positional[0])
+
+	c := mustDialDaemon(*asJSON)
+	defer c.Close()
+	var result folders.RunResult
+	params := struct {
+		Folder string `json:"folder"`
+		folders.RunOptions
+	}{path, opts}
+	if err := c.Call("run", params, &result); err != nil {
+		fail(*asJSON, err)
+	}
+
+	if *asJSON {
+		printJSON(jsonRun{Version: jsonVersion, Folder: path, RunResult: result})
+		return
+	}
+	verb := "Queued"
+	if *dryRun {
+		verb = "Would process"
+	}
+	for _, j := range result.Jobs {
+		fmt.Printf("  %s\n", filepath.Base(j.File))
+	}
+	fmt.Printf("%s %d %s in %s", verb, len(result.Jobs), plural(len(result.Jobs), "file", "files"), path)
+	if result.Skipped > 0 {
+		fmt.Printf(" (%d already processed)", result.Skipped)
+	}
+	fmt.Println()
+}
+
+func printRunHelp() {
+	fmt.Println("gato folder run - Process files already in a folder")
+	fmt.Println()
+	fmt.Println("Usage: gato f run <path> [flags]")
+	fmt.Println()
+	fmt.Println("Queues existing files through the folder's enabled actions, as if they")
+	fmt.Println("had just been added. Actions that already ran on a file, according to")
+	fmt.Println("gato history, are not run again, and files created by actions are")
+	fmt.Println("skipped. Needs the daemon to be running; follow along with gato jobs.")
+	fmt.Println()
+	fmt.Println("Flags:")
+	fmt.Println("  -m, --match <glob>    Only file names matching this, e.g. '*.png'")
+	fmt.Println("  --since <time>        Only files modified after this: 2h, 7d, 2026-10-01")
+	fmt.Println("  -n, --dry-run         List the files without processing them")
+	fmt.Println("  --json                JSON output")
+}
+
+// cmdTest runs a folder's actions, a preset or a command on a temporary
+// copy of a file and reports what happened
+func cmdTest(mgr *folders.Manager, args []string) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	var sel actionFlags
+	sel.register(fs)
+	keepFiles := fs.Bool("keep-files", false, "keep the temporary directory to inspect the results")
+	asJSON := fs.Bool("json", false, "JSON output")
+	positional := parseFlags(fs, args, printTestHelp)
+
+	var target string
+	switch {
+	case len(positional) == 2 && sel.action == "" && sel.command == "" && sel.preset == "":
+		target = positional[0]
+	case len(positional) != 1:
+		printTestHelp()
+		os.Exit(1)
+	}
+	file := expandPath(positional[len(positional)-1])
+	if info, err := os.Stat(file); err != nil {
+		fail(*asJSON, err)
+	} else if info.IsDir() {
+		fail(*asJSON, fmt.Errorf("%s is a directory", file))
+	}
+
+	var actions []folders.FolderAction
+	switch {
+	case target == "":
+		action, command, err := sel.resolve("")
+		if err != nil {
+			fail(*asJSON, err)
+		}
+		if action == "" && command == "" {
+			fail(*asJSON, fmt.Errorf("give a folder or preset, or one of -a, -c and -p"))
+		}
+		actions = append(actions, folders.FolderAction{Action: action, Command: command})
+	case len(mgr.GetFolderActions(expandPath(target))) > 0:
+		// The folder's actions run as configured; params can't change them
+		if fs.Changed("set") {
+			fail(*asJSON, fmt.Errorf("--set only applies to presets, not to the actions of %s", expandPath(target)))
+		}
+		for _, a := range mgr.GetFolderActions(expandPath(target)) {
+			if a.IsEnabled() && (a.Action != "" || a.Command != "") {
+				actions = append(actions, a)
+			}
+		}
+		if len(actions) == 0 {
+			fail(*asJSON, fmt.Errorf("no enabled actions for %s", expandPath(target)))
+		}
+	default:
+		store := presets.New()
+		if err := store.Load(); err != nil {
+			fail(*asJSON, err)
+		}
+		if _, ok := store.Get(target); !ok {
+			fail(*asJSON, fmt.Errorf("%s is not a configured folder or a preset", target))
+		}
+		sel.preset = target
+		_, command, err := sel.resolve("")
+		if err != nil {
+			fail(*asJSON, err)
+		}
+		actions = append(actions, folders.FolderAction{Command: command})
+	}
+	for _, a := range actions {
+		if a.Command != "" {
+			if err := presets.ValidatePlaceholders(a.Command); err != nil {
+				fail(*asJSON, err)
+			}
+		}
+	}
+
+	// The actions log as they would in the daemon; only the report matters here
+	log.SetOutput(io.Discard)
+	dir, results, err := mgr.TestActions(context.Background(), file, actions)
+	if err != nil {
+		os.RemoveAll(dir)
+		fail(*asJSON, err)
+	}
+	if *keepFiles {
+		printTestResults(file, dir, results, *asJSON)
+	} else {
+		printTestResults(file, "", results, *asJSON)
+		os.RemoveAll(dir)
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			os.Exit(1)
+		}
+	}
+}
+
+// printTestResults reports what each action did; dir is shown if set
+func printTestResults(file, dir string, results []folders.TestResult, asJSON bool) {
+	if asJSON {
+		printJSON(jsonTest{Version: jsonVersion, File: file, Dir: dir, Results: results})
+		return
+	}
+
+	name := filepath.Base(file)
+	fmt.Printf("Tested on a copy of %s\n", name)
+	for _, r := range results {
+		fmt.Println()
+		fmt.Printf("%s\n", truncate(strings.TrimPrefix(r.Action, "custom: "), 70))
+		if r.Skipped != "" {
+			fmt.Printf("  skipped: %s\n", r.Skipped)
+			continue
+		}
+		status := "ok"
+		if r.Error != "" {
+			status = "failed: " + r.Error
+		}
+		fmt.Printf("  %s in %s (exit code %d)\n", status, formatSeconds(r.Duration), r.ExitCode)
+		switch {
+		case r.After < 0:
+			fmt.Printf("  %s removed\n", name)
+		case r.Replaced:
+			fmt.Printf("  %s %s -> %s\n", name, originals.FormatSize(r.Before), originals.FormatSize(r.After))
+		}
+		for _, o := range r.Outputs {
+			fmt.Printf("  + %s %s\n", filepath.Base(o.Path), originals.FormatSize(o.Size))
+		}
+		if out := strings.TrimSpace(r.Output); out != "" {
+			fmt.Println("  output:")
+			for _, line := range strings.Split(out, "\n") {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+	}
+	if dir != "" {
+		fmt.Printf("\nResults are in %s\n", filepath.Join(dir, "files"))
+	}
+}
+
+// formatSeconds formats short durations with more precision than formatDuration
+func formatSeconds(s float64) string {
+	if s < 60 {
+		return fmt.Sprintf("%.2fs", s)
+	}
+	return formatDuration(time.Duration(s * float64(time.Second)))
+}
+
+func printTestHelp() {
+	fmt.Println("gato folder test - Try actions on a copy of a file")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  gato f test <folder> <file>            Run the folder's enabled actions")
+	fmt.Println("  gato f test <preset> <file> [--set k=v] Run a preset")
+	fmt.Println("  gato f test -a <action> <file>         Run a predefined action")
+	fmt.Println("  gato f test -c <command> <file>        Run a command before adding it")
+	fmt.Println()
+	fmt.Println("The file is copied to a temporary directory and the actions run there, in")
+	fmt.Println("order, so the real folder, the history and the Trash are not touched.")
+	fmt.Println("Reports the files produced, sizes before and after, duration, exit code")
+	fmt.Println("and output of each action.")
+	fmt.Println()
+	fmt.Println("Flags:")
+	fmt.Println("  --keep-files          Keep the temporary directory to look at the results")
+	fmt.Println("  --json                JSON output")
+}
+
+// cmdCheck validates the config and user presets. Errors exit with 1,
+// warnings alone do not.
+func cmdCheck(mgr *folders.Manager, args []string) {
+	fs := pflag.NewFlagSet("check", pflag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "JSON output")
+	positional := parseFlags(fs, args, printCheckHelp)
+
+	if len(positional) > 1 {
+		printCheckHelp()
+		os.Exit(1)
+	}
+
+	issues := mgr.CheckFile()
+	if len(positional) == 1 {
+		path := expandPath(positional[0])
+		if len(mgr.GetFolderActions(path)) == 0 {
+			fail(*asJSON, fmt.Errorf("folder not found: %s", path))
+		}
+		var filtered []folders.Issue
+		for _, i := range issues {
+			if i.Path == path {
+				filtered = append(filtered, i)
+			}
+		}
+		issues = filtered
+	}
+
+	if *asJSON {
+		printJSON(jsonCheck{Version: jsonVersion, Issues: append([]folders.Issue{}, issues...)})
+	} else {
+		errs := 0
+		for _, i := range issues {
+			if i.Severity == folders.IssueError {
+				errs++
+			}
+			fmt.Printf("%-8s %s\n", i.Severity, i)
+		}
+		if len(issues) == 0 {
+			fmt.Println("No problems found")
+		} else {
+			fmt.Printf("\n%d %s, %d %s\n", errs, plural(errs, "error", "errors"),
+				len(issues)-errs, plural(len(issues)-errs, "warning", "warnings"))
+		}
+	}
+	if folders.HasErrors(issues) {
+		os.Exit(1)
+	}
+}
+
+func printCheckHelp() {
+	fmt.Println("gato folder check - Look for problems in the config")
+	fmt.Println()
+	fmt.Println("Usage: gato f check [path] [--json]")
+	fmt.Println()
+	fmt.Println("Checks folders.toml and user presets for unknown actions, invalid")
+	fmt.Println("placeholders, programs that are not installed, missing or overlapping")
+	fmt.Println("folders, and actions that would process their own output or pass files")
+	fmt.Println("back and forth between folders. The daemon runs the same checks when the")
+	fmt.Println("config changes and notifies about errors.")
+	fmt.Println()
+	fmt.Println("Exits with 1 if there are errors; warnings alone exit with 0.")
+}
+
+func printUndoHelp() {
+	fmt.Println("gato folder undo - Revert processed files")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  gato f undo                            Undo the last processed file")
+	fmt.Println("  gato f undo <file>                     Undo the last processing of a file")
+	fmt.Println("  gato f undo --last <n>                 Undo the last n processed files")
+	fmt.Println()
+	fmt.Println("Originals are restored from .originals/, or from the Trash when a conversion")
+	fmt.Println("moved them there, and files created by the actions are moved to the Trash.")
+	fmt.Println("Files changed in place without -k cannot be restored. Restored files are not")
+	fmt.Println("processed again for 30 seconds.")
+}
+
+func printOriginalsHelp() {
+	fmt.Println("gato folder originals - Manage kept originals")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  gato f originals <path>                List stored versions, newest first")
+	fmt.Println("  gato f originals <path> --prune        Apply the retention policy now")
+	fmt.Println()
+	fmt.Println("Flags:")
+	fmt.Println("  --max-age <age>       Drop versions older than this, e.g. 30d, 2w (default 30d, 0 = forever)")
+	fmt.Println("  --max-size <size>     Cap the folder's store, e.g. 500M, 2G (default no limit)")
+	fmt.Println("  --prune               Remove expired versions without changing the policy")
+	fmt.Println()
+	fmt.Println("Actions added with -k store every version of a file, once per distinct")
+	fmt.Println("content. Pruning never removes the newest version of each file, so its")
+	fmt.Println("last change can always be undone, nor copies imported from .originals")
+	fmt.Println("folders written by older versions of gato (marked \"imported\").")
+}
+
+func printStatusHelp() {
+	fmt.Println("gato folder status - Check configured folders")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  gato f status [path]")
+	fmt.Println()
+	fmt.Println("Flags:")
+	fmt.Println("  --json                JSON output")
+}
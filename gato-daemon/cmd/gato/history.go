@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/veinticinco/gato-daemon/internal/folders"
+	"github.com/veinticinco/gato-daemon/internal/history"
+	"github.com/veinticinco/gato-daemon/internal/originals"
+)
+
+func handleHistory(args []string) {
+	fs := pflag.NewFlagSet("history", pflag.ContinueOnError)
+	folder := fs.StringP("folder", "f", "", "only jobs in this folder")
+	status := fs.StringP("status", "s", "", "ok, failed, cancelled or undone")
+	since := fs.String("since", "", "only jobs started after this (e.g. 2h, 7d, 2026-10-01)")
+	until := fs.String("until", "", "only jobs started before this")
+	limit := fs.IntP("limit", "n", 20, "show at most this many jobs (0 = all)")
+	asJSON := fs.Bool("json", false, "JSON output")
+	parseFlags(fs, args, printHistoryHelp)
+
+	filter := history.Filter{Status: *status, Limit: *limit}
+	if *folder != "" {
+		filter.Folder = expandPath(*folder)
+	}
+	if *status != "" && !contains(history.Statuses, *status) {
+		fail(*asJSON, fmt.Errorf("unknown status: %s (available: %s)", *status, strings.Join(history.Statuses, ", ")))
+	}
+	var err error
+	if filter.Since, err = parseTime(*since); err != nil {
+		fail(*asJSON, err)
+	}
+	if filter.Until, err = parseTime(*until); err != nil {
+		fail(*asJSON, err)
+	}
+
+	jobs, err := history.New(folders.StateDir()).Query(filter)
+	if err != nil {
+		fail(*asJSON, err)
+	}
+
+	if *asJSON {
+		out := jsonHistory{Version: jsonVersion, Jobs: []jsonJob{}}
+		for _, j := range jobs {
+			out.Jobs = append(out.Jobs, jsonJob{Job: j, Status: j.Status()})
+		}
+		printJSON(out)
+		return
+	}
+
+	if len(jobs) == 0 {
+		fmt.Println("No jobs recorded")
+		return
+	}
+	for _, j := range jobs {
+		sizes := originals.FormatSize(j.Before)
+		if j.After != j.Before {
+			sizes += " -> " + originals.FormatSize(j.After)
+		}
+		fmt.Printf("%s  %-9s  %-28s  %-20s  %s\n",
+			j.Start.Local().Format("2006-01-02 15:04:05"), j.Status(),
+			truncate(filepath.Base(j.File), 28), sizes, truncate(strings.TrimPrefix(j.Action, "custom: "), 40))
+		if j.Error != "" && !j.Cancelled {
+			fmt.Printf("%21s%s\n", "", j.Error)
+		}
+	}
+}
+
+// parseTime accepts a duration ago (2h, 7d), a date or a date and time
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := originals.ParseAge(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s (use e.g. 2h, 7d, 2026-10-01 or 2026-10-01 15:04)", s)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func printHistoryHelp() {
+	fmt.Println("gato history - Show what the daemon did")
+	fmt.Println()
+	fmt.Println("Usage: gato history [flags]")
+	fmt.Println()
+	fmt.Println("Flags:")
+	fmt.Println("  -f, --folder <path>   Only jobs in this folder")
+	fmt.Println("  -s, --status <status> ok, failed, cancelled or undone")
+	fmt.Println("  --since <time>        Started after this: 2h, 7d, 2026-10-01, 2026-10-01 15:04")
+	fmt.Println("  --until <time>        Started before this")
+	fmt.Println("  -n, --limit <n>       Show at most n jobs, newest first (default 20, 0 = all)")
+	fmt.Println("  --json                JSON output with every recorded field")
+	fmt.Println()
+	fmt.Println("The daemon keeps the last 90 days, up to 20000 jobs; older jobs can't be undone.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  gato history -s failed --since 1d")
+	fmt.Println("  gato history -f ~/Photos -n 0 --json")
+}
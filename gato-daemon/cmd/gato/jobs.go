@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/veinticinco/gato-daemon/internal/folders"
+	"github.com/veinticinco/gato-daemon/internal/rpc"
+)
+
+func handleJobs(args []string) {
+	if len(args) > 0 && args[0] == "cancel" {
+		cmdCancelJobs(args[1:])
+		return
+	}
+
+	fs := pflag.NewFlagSet("jobs", pflag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "JSON output")
+	if rest := parseFlags(fs, args, printJobsHelp); len(rest) > 0 {
+		fail(*asJSON, fmt.Errorf("unknown jobs command: %s", rest[0]))
+	}
+
+	c := mustDialDaemon(*asJSON)
+	defer c.Close()
+	var jobs []folders.Job
+	if err := c.Call("jobs", nil, &jobs); err != nil {
+		fail(*asJSON, err)
+	}
+
+	if *asJSON {
+		if jobs == nil {
+			jobs = []folders.Job{}
+		}
+		printJSON(jsonJobs{Version: jsonVersion, Jobs: jobs})
+		return
+	}
+	if len(jobs) == 0 {
+		fmt.Println("No jobs queued")
+		return
+	}
+	for _, j := range jobs {
+		elapsed := time.Since(j.Queued)
+		if j.Started != nil {
+			elapsed = time.Since(*j.Started)
+		}
+		line := fmt.Sprintf("%-8s %s  %-28s %6s", j.State, j.ID, truncate(filepath.Base(j.File), 28), formatDuration(elapsed))
+		if j.Action != "" {
+			line += "  " + truncate(strings.TrimPrefix(j.Action, "custom: "), 30)
+		}
+		if j.Progress > 0 {
+			line += fmt.Sprintf(" %d%%", int(j.Progress*100))
+		}
+		fmt.Println(line)
+		fmt.Printf("         in %s\n", j.Folder)
+	}
+}
+
+// cmdCancelJobs stops jobs by ID or every job in a folder
+func cmdCancelJobs(args []string) {
+	fs := pflag.NewFlagSet("jobs cancel", pflag.ContinueOnError)
+	folder := fs.StringP("folder", "f", "", "cancel every job in this folder")
+	asJSON := fs.Bool("json", false, "JSON output")
+	ids := parseFlags(fs, args, printJobsHelp)
+
+	if (*folder == "") == (len(ids) == 0) {
+		fail(*asJSON, fmt.Errorf("give job IDs or --folder"))
+	}
+
+	c := mustDialDaemon(*asJSON)
+	defer c.Close()
+
+	cancelled := []folders.Job{}
+	failed := false
+	if *folder != "" {
+		if err := c.Call("cancel", map[string]string{"folder": expandPath(*folder)}, &cancelled); err != nil {
+			fail(*asJSON, err)
+		}
+	}
+	for _, id := range ids {
+		var jobs []folders.Job
+		if err := c.Call("cancel", map[string]string{"id": id}, &jobs); err != nil {
+			if *asJSON || len(ids) == 1 {
+				fail(*asJSON, err)
+			}
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed = true
+			continue
+		}
+		cancelled = append(cancelled, jobs...)
+	}
+
+	if *asJSON {
+		printJSON(jsonJobs{Version: jsonVersion, Jobs: cancelled})
+		return
+	}
+	for _, j := range cancelled {
+		fmt.Printf("Cancelled %s %s (%s)\n", j.ID, filepath.Base(j.File), j.State)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// mustDialDaemon connects to the daemon or exits
+func mustDialDaemon(asJSON bool) *rpc.Client {
+	c := dialDaemon()
+	if c == nil {
+		fail(asJSON, fmt.Errorf("gato-daemon is not running"))
+	}
+	return c
+}
+
+func printJobsHelp() {
+	fmt.Println("gato jobs - List and cancel the daemon's queued and running work")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  gato jobs [--json]                          List jobs with elapsed time")
+	fmt.Println("  gato jobs cancel <id>... [--json]           Cancel jobs by ID or a unique prefix")
+	fmt.Println("                                              of at least 4 characters")
+	fmt.Println("  gato jobs cancel --folder <path> [--json]   Cancel every job in a folder")
+	fmt.Println()
+	fmt.Println("Cancelling a running job stops the whole process group of its action,")
+	fmt.Println("deletes partial outputs named after the file and, if the file was changed")
+	fmt.Println("in place, restores it from its kept original. It is recorded in the")
+	fmt.Println("history as cancelled.")
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/veinticinco/gato-daemon/internal/folders"
+	"github.com/veinticinco/gato-daemon/internal/history"
+)
+
+// jsonVersion is bumped only on incompatible schema changes; new fields may
+// be added at any time
+const jsonVersion = 1
+
+// jsonFolder is one folder with all of its actions
+type jsonFolder struct {
+	Path        string                 `json:"path"`
+	Exists      bool                   `json:"exists"`
+	Enabled     bool                   `json:"enabled"`
+	PausedUntil *time.Time             `json:"paused_until"`
+	Actions     []folders.FolderAction `json:"actions"`
+}
+
+// jsonList is the output of gato f ls --json
+type jsonList struct {
+	Version int          `json:"version"`
+	Folders []jsonFolder `json:"folders"`
+}
+
+// jsonChange is the output of gato f add/rm --json
+type jsonChange struct {
+	Version int                    `json:"version"`
+	Folder  string                 `json:"folder"`
+	Added   []folders.FolderAction `json:"added"`
+	Removed []folders.FolderAction `json:"removed"`
+}
+
+// jsonFolderStatus describes whether a configured folder can be watched
+type jsonFolderStatus struct {
+	Path    string `json:"path"`
+	State   string `json:"state"` // ok, paused, missing, not-a-directory, error
+	Error   string `json:"error"`
+	Actions int    `json:"actions"`
+}
+
+// jsonStatus is the output of gato f status --json
+type jsonStatus struct {
+	Version int                `json:"version"`
+	Folders []jsonFolderStatus `json:"folders"`
+}
+
+// jsonJob is one job with its derived status
+type jsonJob struct {
+	history.Job
+	Status string `json:"status"` // ok, failed, cancelled, undone
+}
+
+// jsonHistory is the output of gato history --json
+type jsonHistory struct {
+	Version int       `json:"version"`
+	Jobs    []jsonJob `json:"jobs"`
+}
+
+// jsonDaemon is the output of gato status --json
+type jsonDaemon struct {
+	Version int                   `json:"version"`
+	Running bool                  `json:"running"`
+	Daemon  *folders.DaemonStatus `json:"daemon"` // null when not running
+}
+
+// jsonJobs is the output of gato jobs and gato jobs cancel
+type jsonJobs struct {
+	Version int           `json:"version"`
+	Jobs    []folders.Job `json:"jobs"`
+}
+
+// jsonRun is the output of gato f run --json
+type jsonRun struct {
+	Version int    `json:"version"`
+	Folder  string `json:"folder"`
+	folders.RunResult
+}
+
+// jsonCheck is the output of gato f check --json
+type jsonCheck struct {
+	Version int             `json:"version"`
+	Issues  []folders.Issue `json:"issues"`
+}
+
+// jsonTest is the output of gato f test --json
+type jsonTest struct {
+	Version int                  `json:"version"`
+	File    string               `json:"file"`
+	Dir     string               `json:"dir,omitempty"` // with --keep-files
+	Results []folders.TestResult `json:"results"`
+}
+
+type jsonError struct {
+	Version int    `json:"version"`
+	Error   string `json:"error"`
+}
+
+func printJSON(v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
+
+// fail reports err and exits, as a JSON object when asJSON is set
+func fail(asJSON bool, err error) {
+	if asJSON {
+		printJSON(jsonError{Version: jsonVersion, Error: err.Error()})
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	os.Exit(1)
+}
+
+// folderJSON collects a folder's actions; actions is never null
+func folderJSON(mgr *folders.Manager, path string) jsonFolder {
+	_, err := os.Stat(path)
+	actions := mgr.GetFolderActions(path)
+	if actions == nil {
+		actions = []folders.FolderAction{}
+	}
+	folder := jsonFolder{Path: path, Exists: err == nil, Enabled: mgr.FolderEnabled(path), Actions: actions}
+	if until := mgr.FolderSettings(path).PausedUntil; !folder.Enabled && !until.IsZero() {
+		folder.PausedUntil = &until
+	}
+	return folder
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/veinticinco/gato-daemon/internal/presets"
+)
+
+func handlePreset(args []string) {
+	if len(args) == 0 {
+		args = []string{"ls"}
+	}
+
+	store := presets.New()
+	if err := store.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		cmdPresetList(store, args[1:])
+	case "show":
+		cmdPresetShow(store, args[1:])
+	case "add", "a":
+		cmdPresetSave(store, args[1:], false)
+	case "edit", "e":
+		cmdPresetSave(store, args[1:], true)
+	case "remove", "rm":
+		cmdPresetRemove(store, args[1:])
+	case "-h", "--help", "help":
+		printPresetHelp()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown subcommand: %s\n\n", args[0])
+		printPresetHelp()
+		os.Exit(1)
+	}
+}
+
+func cmdPresetList(store *presets.Store, args []string) {
+	fs := pflag.NewFlagSet("ls", pflag.ContinueOnError)
+	raw := fs.Bool("raw", false, "tab-separated output")
+	asJSON := fs.Bool("json", false, "JSON output")
+	parseFlags(fs, args, printPresetHelp)
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(store.List(), "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	if *raw {
+		for _, p := range store.List() {
+			kind := "user"
+			if p.Builtin {
+				kind = "builtin"
+			}
+			fmt.Printf("%s\t%s\t%s\n", p.Name, kind, p.Command)
+		}
+		return
+	}
+
+	fmt.Println("Builtin:")
+	for _, p := range presets.Builtins() {
+		fmt.Printf("  %-12s %s%s\n", p.Name, p.Description, formatParams(p.Params))
+	}
+
+	user := store.User()
+	fmt.Println()
+	if len(user) == 0 {
+		fmt.Println("No user presets. Add one:")
+		fmt.Println("  gato preset add thumb \"convert {} -resize 256x256 {dir}/{name}_thumb{ext}\"")
+		return
+	}
+	fmt.Println("User:")
+	for _, p := range user {
+		display := p.Command
+		if len(display) > 60 {
+			display = display[:57] + "..."
+		}
+		fmt.Printf("  %-12s %s%s\n", p.Name, display, formatParams(p.Params))
+	}
+}
+
+// formatParams renders params as " [name=default ...]" for listings
+func formatParams(params []presets.Param) string {
+	if len(params) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, p := range params {
+		parts = append(parts, p.Name+"="+p.Default)
+	}
+	return " [" + strings.Join(parts, " ") + "]"
+}
+
+func cmdPresetShow(store *presets.Store, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: gato preset show <name>")
+		os.Exit(1)
+	}
+
+	p, ok := store.Get(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown preset: %s\n", args[0])
+		os.Exit(1)
+	}
+
+	kind := "user"
+	if p.Builtin {
+		kind = "builtin"
+	}
+	fmt.Printf("%s (%s)\n", p.Name, kind)
+	if p.Description != "" {
+		fmt.Printf("  %s\n", p.Description)
+	}
+	fmt.Printf("  command:  %s\n", p.Command)
+	for _, param := range p.Params {
+		fmt.Printf("  param:    %s\n", param)
+	}
+	fmt.Printf("  programs: %s\n", strings.Join(presets.Binaries(p.Command), ", "))
+	if missing := presets.MissingBinaries(p.Command); len(missing) > 0 {
+		fmt.Printf("  missing:  %s\n", strings.Join(missing, ", "))
+	}
+}
+
+func cmdPresetSave(store *presets.Store, args []string, edit bool) {
+	fs := pflag.NewFlagSet("add", pflag.ContinueOnError)
+	decls := fs.StringArray("param", nil, "param declaration")
+	positional := parseFlags(fs, args, printPresetHelp)
+
+	var params []presets.Param
+	for _, decl := range *decls {
+		param, err := presets.ParseParam(decl)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		params = append(params, param)
+	}
+
+	if len(positional) < 2 {
+		if edit {
+			fmt.Fprintln(os.Stderr, "Usage: gato preset edit <name> <command> [--param <decl>]...")
+		} else {
+			fmt.Fprintln(os.Stderr, "Usage: gato preset add <name> <command> [--param <decl>]...")
+		}
+		os.Exit(1)
+	}
+
+	name, command := positional[0], positional[1]
+	if edit && !store.Exists(name) {
+		fmt.Fprintf(os.Stderr, "Error: preset not found: %s\n", name)
+		os.Exit(1)
+	}
+	if !edit && store.Exists(name) {
+		fmt.Fprintf(os.Stderr, "Error: preset %s already exists (use gato preset edit)\n", name)
+		os.Exit(1)
+	}
+	// Editing only the command keeps the declared params
+	if edit && !fs.Changed("param") {
+		existing, _ := store.Get(name)
+		params = existing.Params
+	}
+
+	if err := store.Set(name, command, params); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if edit {
+		fmt.Printf("Updated preset: %s\n", name)
+	} else {
+		fmt.Printf("Added preset: %s\n", name)
+	}
+	if missing := presets.MissingBinaries(command); len(missing) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: not installed: %s\n", strings.Join(missing, ", "))
+	}
+}
+
+func cmdPresetRemove(store *presets.Store, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: gato preset rm <name>")
+		os.Exit(1)
+	}
+
+	if err := store.Delete(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Removed preset: %s\n", args[0])
+}
+
+func printPresetHelp() {
+	fmt.Println("gato preset - Manage command presets")
+	fmt.Println()
+	fmt.Println("Usage: gato preset <command> [args]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  ls, list           List presets (default, --json for all fields)")
+	fmt.Println("  show <name>        Show a preset's command, params and required programs")
+	fmt.Println("  add <name> <cmd>   Create a user preset")
+	fmt.Println("  edit <name> <cmd>  Replace a user preset's command, and its params if")
+	fmt.Println("                     --param is given")
+	fmt.Println("  rm <name>          Delete a user preset")
+	fmt.Println()
+	fmt.Println("Params:")
+	fmt.Println("  --param <decl>     Declare a param, referenced in the command as {name}")
+	fmt.Println("                     percent:int=50  quality:int 1..100=75  format:enum(webp|png)=webp")
+	fmt.Println("  Values are filled in when adding the preset to a folder:")
+	fmt.Println("    gato f add ~/Photos -p resize --set percent=33")
+	fmt.Println()
+	fmt.Println("User presets are shared with Gato Carpetas (~/.config/gato/presets.json).")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  gato preset add thumb \"convert {} -resize 256x256 {dir}/{name}_thumb{ext}\"")
+	fmt.Println("  gato preset add thumb \"convert {} -resize {size} {dir}/{name}_thumb{ext}\" --param size:string=256x256")
+	fmt.Println("  gato preset show webp")
+	fmt.Println("  gato f add ~/Photos -p thumb")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/pflag"
+	"github.com/veinticinco/gato-daemon/internal/folders"
+	"github.com/veinticinco/gato-daemon/internal/history"
+	"github.com/veinticinco/gato-daemon/internal/presets"
+)
+
+// handleProcess runs an action on the given files once, without a folder
+func handleProcess(args []string) {
+	fs := pflag.NewFlagSet("process", pflag.ContinueOnError)
+	var sel actionFlags
+	sel.register(fs)
+	keepOriginal := fs.BoolP("keep", "k", false, "keep originals in .originals/ next to each file")
+	notifyLevel := fs.StringP("notify", "n", folders.NotifyNever, "when to notify")
+	verbose := fs.BoolP("verbose", "v", false, "log each step")
+	asJSON := fs.Bool("json", false, "JSON output")
+	files := parseFlags(fs, args, printProcessHelp)
+
+	if len(files) == 0 {
+		printProcessHelp()
+		os.Exit(1)
+	}
+	action, command, err := sel.resolve("")
+	if err != nil {
+		fail(*asJSON, err)
+	}
+	if action == "" && command == "" {
+		fail(*asJSON, fmt.Errorf("choose what to run with -a, -c or -p"))
+	}
+	if command != "" {
+		if err := presets.ValidatePlaceholders(command); err != nil {
+			fail(*asJSON, err)
+		}
+		if missing := presets.MissingBinaries(command); len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: not installed: %s\n", strings.Join(missing, ", "))
+		}
+	}
+	if err := folders.ValidateNotifyLevel(*notifyLevel); err != nil {
+		fail(*asJSON, err)
+	}
+
+	var paths []string
+	for _, f := range files {
+		path := expandPath(f)
+		info, err := os.Stat(path)
+		if err != nil {
+			fail(*asJSON, err)
+		}
+		if info.IsDir() {
+			fail(*asJSON, fmt.Errorf("%s is a directory (use gato f run for folders)", f))
+		}
+		paths = append(paths, path)
+	}
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	mgr := folders.New()
+	if err := mgr.LoadConfig(); err != nil {
+		fail(*asJSON, err)
+	}
+
+	// Ctrl+C cancels like gato jobs cancel, cleaning up partial outputs
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	jobs, err := mgr.ProcessFiles(ctx, paths, folders.FolderAction{
+		Action:       action,
+		Command:      command,
+		KeepOriginal: *keepOriginal,
+		NotifyLevel:  *notifyLevel,
+	})
+	if err != nil {
+		fail(*asJSON, err)
+	}
+
+	byFile := make(map[string]history.Job)
+	for _, j := range jobs {
+		byFile[j.File] = j
+	}
+	if *asJSON {
+		out := jsonHistory{Version: jsonVersion, Jobs: []jsonJob{}}
+		for _, j := range jobs {
+			out.Jobs = append(out.Jobs, jsonJob{Job: j, Status: j.Status()})
+		}
+		printJSON(out)
+	}
+
+	failed := 0
+	for _, path := range paths {
+		j, ok := byFile[path]
+		switch {
+		case !ok:
+			if !*asJSON {
+				fmt.Printf("skipped    %s\n", filepath.Base(path))
+			}
+		case j.Status() != history.StatusOK:
+			failed++
+			if !*asJSON {
+				fmt.Printf("%-10s %s: %s\n", j.Status(), filepath.Base(path), j.Error)
+			}
+		case !*asJSON:
+			line := fmt.Sprintf("ok         %s", filepath.Base(path))
+			if len(j.Outputs) > 0 {
+				var names []string
+				for _, out := range j.Outputs {
+					names = append(names, filepath.Base(out))
+				}
+				line += " -> " + strings.Join(names, ", ")
+			}
+			fmt.Println(line)
+		}
+	}
+	if failed > 0 || ctx.Err() != nil {
+		os.Exit(1)
+	}
+}
+
+func printProcessHelp() {
+	fmt.Println("gato process - Run an action on files once, without configuring a folder")
+	fmt.Println()
+	fmt.Println("Usage: gato process (-a <action> | -c <command> | -p <preset>) [flags] <file>...")
+	fmt.Println()
+	fmt.Println("Files are processed in parallel like in watched folders: converted")
+	fmt.Println("originals go to the Trash, and each file shows up in gato history and")
+	fmt.Println("can be reverted with gato f undo.")
+	fmt.Println()
+	fmt.Println("Flags:")
+	fmt.Println("  -a, --action <name>   Predefined action (compress, convert-webp, ...)")
+	fmt.Println("  -c, --command <cmd>   Custom command ({} = file, {name}, {ext}, {dir})")
+	fmt.Println("  -p, --preset <name>   Preset command, with --set key=value for its params")
+	fmt.Println("  -k, --keep            Keep originals in .originals/ next to each file")
+	fmt.Println("  -n, --notify <level>  never (default), failure, always or summary")
+	fmt.Println("  -v, --verbose         Log each step to stderr")
+	fmt.Println("  --json                JSON output with the history of each file")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  gato process -p webp ~/Desktop/*.png")
+	fmt.Println("  gato process -a compress -k photo.jpg")
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/veinticinco/gato-daemon/internal/folders"
+)
+
+func handleStatus(args []string) {
+	fs := pflag.NewFlagSet("status", pflag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "JSON output")
+	parseFlags(fs, args, printStatusCommandHelp)
+
+	status, running, err := daemonStatus()
+	if err != nil {
+		fail(*asJSON, err)
+	}
+
+	if *asJSON {
+		out := jsonDaemon{Version: jsonVersion, Running: running}
+		if running {
+			out.Daemon = &status
+		}
+		printJSON(out)
+		if !running {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !running {
+		fmt.Println("gato-daemon is not running")
+		os.Exit(1)
+	}
+
+	fmt.Printf("gato-daemon v%s running (pid %d, up %s)\n", status.Version, status.PID, formatDuration(time.Since(status.Started)))
+
+	fmt.Println()
+	fmt.Println("Folders:")
+	if len(status.Folders) == 0 {
+		fmt.Println("  none configured")
+	}
+	for _, w := range status.Folders {
+		detail := fmt.Sprintf("%d %s", w.Actions, plural(w.Actions, "action", "actions"))
+		switch w.State {
+		case folders.WatchPaused:
+			detail = "paused"
+			if w.PausedUntil != nil {
+				detail += " until " + w.PausedUntil.Local().Format("15:04")
+			}
+		case folders.WatchIdle:
+			detail = "no enabled actions"
+		}
+		fmt.Printf("  %-9s %s (%s)\n", w.State, w.Path, detail)
+		if w.LastError != "" {
+			fmt.Printf("            last error %s: %s\n", w.ErrorTime.Local().Format("15:04:05"), w.LastError)
+		}
+	}
+
+	if len(status.Issues) > 0 {
+		fmt.Println()
+		fmt.Println("Config problems (see gato f check):")
+		for _, i := range status.Issues {
+			fmt.Printf("  %-9s %s\n", i.Severity, i)
+		}
+	}
+
+	fmt.Println()
+	if len(status.Jobs) == 0 {
+		fmt.Println("No jobs queued")
+		return
+	}
+	queued := 0
+	for _, j := range status.Jobs {
+		if j.State == folders.JobQueued {
+			queued++
+		}
+	}
+	fmt.Printf("Jobs (%d running, %d queued):\n", len(status.Jobs)-queued, queued)
+	for _, j := range status.Jobs {
+		elapsed := time.Since(j.Queued)
+		if j.Started != nil {
+			elapsed = time.Since(*j.Started)
+		}
+		progress := ""
+		if j.Progress > 0 {
+			progress = fmt.Sprintf("%d%%", int(j.Progress*100))
+		}
+		line := fmt.Sprintf("  %-8s %s  %-28s %6s %4s  %s", j.State, j.ID, truncate(filepath.Base(j.File), 28),
+			formatDuration(elapsed), progress, truncate(strings.TrimPrefix(j.Action, "custom: "), 30))
+		if j.ETA != nil && time.Until(*j.ETA) > 0 {
+			line += fmt.Sprintf(" (%s left)", formatDuration(time.Until(*j.ETA)))
+		}
+		fmt.Println(line)
+	}
+}
+
+// daemonStatus asks the daemon over its socket, falling back to the status
+// file it publishes
+func daemonStatus() (folders.DaemonStatus, bool, error) {
+	if c := dialDaemon(); c != nil {
+		defer c.Close()
+		var status folders.DaemonStatus
+		if err := c.Call("status", nil, &status); err == nil {
+			return status, true, nil
+		}
+	}
+	return folders.ReadStatus()
+}
+
+// formatDuration formats e.g. 2h3m, 4m12s or 9s
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm%ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
+
+func printStatusCommandHelp() {
+	fmt.Println("gato status - Show whether the daemon is running and what it is doing")
+	fmt.Println()
+	fmt.Println("Usage: gato status [--json]")
+	fmt.Println()
+	fmt.Println("Shows the daemon version and uptime, the watch state of each folder with")
+	fmt.Println("its last error, problems found in the config, and queued and running jobs")
+	fmt.Println("with their progress when known. Exits with 1 if the daemon is not running.")
+}
@@ -0,0 +1,363 @@
+package folders
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/veinticinco/gato-daemon/internal/notify"
+	"github.com/veinticinco/gato-daemon/internal/originals"
+	"github.com/veinticinco/gato-daemon/internal/presets"
+)
+
+// Issue severities
+const (
+	IssueError   = "error"   // the folder or action cannot work as configured
+	IssueWarning = "warning" // it works, but probably not as intended
+)
+
+// Issue is a problem found in the configuration
+type Issue struct {
+	Severity string `json:"severity"`
+	Path     string `json:"path"`      // folder, empty for the whole config
+	ActionID string `json:"action_id"` // empty for folder-wide problems
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	where := i.Path
+	if i.ActionID != "" {
+		where += " [" + i.ActionID + "]"
+	}
+	if where == "" {
+		return i.Message
+	}
+	return where + ": " + i.Message
+}
+
+// HasErrors reports whether any of the issues is an error
+func HasErrors(issues []Issue) bool {
+	return slices.ContainsFunc(issues, func(i Issue) bool { return i.Severity == IssueError })
+}
+
+// predefinedBinaries are the programs each predefined action needs
+var predefinedBinaries = map[string][]string{
+	"compress":     {"convert"},
+	"convert-webp": {"convert"},
+	"convert-mp4":  {"ffmpeg"},
+	"convert-mp3":  {"ffmpeg"},
+	"resize-50":    {"convert"},
+	"resize-25":    {"convert"},
+}
+
+// predefinedOutputs are the extensions predefined actions create next to the file
+var predefinedOutputs = map[string]string{
+	"convert-webp": ".webp",
+	"convert-mp4":  ".mp4",
+	"convert-mp3":  ".mp3",
+}
+
+// outputPathRe finds paths a command writes to: {dir}/..., ~/... or absolute paths
+var outputPathRe = regexp.MustCompile(`(?:^|[\s'"=])((?:\{dir\}|~)?/[^\s'";&|>]+)`)
+
+// CheckConfig looks for problems in the loaded configuration and in the
+// user presets: unknown actions, invalid placeholders, missing programs,
+// folders that overlap and actions that would process their own output
+func (m *Manager) CheckConfig() []Issue {
+	issues := checkConfig(m.config)
+	return append(issues, checkPresets()...)
+}
+
+// CheckFile reads folders.toml as it is on disk and checks it like
+// CheckConfig. Nothing is written, not even IDs for actions that lack one,
+// so the config stays loaded as read. A file that cannot be parsed is
+// reported as an error.
+func (m *Manager) CheckFile() []Issue {
+	data, err := os.ReadFile(m.configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return []Issue{{Severity: IssueError, Message: err.Error()}}
+	}
+	var config Config
+	if err := toml.Unmarshal(data, &config); err != nil {
+		return []Issue{{Severity: IssueError, Message: fmt.Sprintf("%s: %v", m.configPath, err)}}
+	}
+	m.config = config
+	return m.CheckConfig()
+}
+
+// reportConfig logs the problems found when the config is (re)loaded and
+// notifies when it has errors. Unchanged problems are reported once, since
+// every change from the CLI reloads. Only called from the Start loop.
+func (m *Manager) reportConfig(issues []Issue) {
+	key := fmt.Sprint(issues)
+	previous := m.reported
+	if key == previous {
+		return
+	}
+	m.reported = key
+	m.setIssues(issues)
+
+	if len(issues) == 0 {
+		if previous != "" {
+			log.Println("Config problems fixed")
+		}
+		return
+	}
+	var errs []Issue
+	for _, i := range issues {
+		log.Printf("Config %s: %s", i.Severity, i)
+		if i.Severity == IssueError {
+			errs = append(errs, i)
+		}
+	}
+	if len(errs) == 0 {
+		return
+	}
+
+	body := errs[0].String()
+	if len(errs) > 1 {
+		body += fmt.Sprintf("\n(and %d more, see gato f check)", len(errs)-1)
+	}
+	configPath := m.configPath
+	m.send(notify.Notification{
+		Summary: "Folder config has errors",
+		Body:    body,
+		Urgency: notify.UrgencyCritical,
+		Actions: []notify.Action{
+			{Key: "default", Label: "Open config", Run: func() { notify.Open(configPath) }},
+			{Key: "log", Label: "View log", Run: func() { notify.Open(LogPath()) }},
+		},
+	})
+}
+
+// checkConfig validates a configuration without touching the manager, so
+// changes can be checked before they are saved
+func checkConfig(cfg Config) []Issue {
+	var issues []Issue
+	add := func(severity, path, id, format string, args ...any) {
+		issues = append(issues, Issue{Severity: severity, Path: path, ActionID: id, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var paths []string
+	ids := make(map[string]bool)
+	for _, a := range cfg.Folders {
+		if !slices.Contains(paths, a.Path) {
+			paths = append(paths, a.Path)
+		}
+		if a.ID != "" {
+			if ids[a.ID] {
+				add(IssueError, a.Path, a.ID, "ID is used by more than one action")
+			}
+			ids[a.ID] = true
+		}
+		for _, msg := range checkAction(a) {
+			add(msg.Severity, a.Path, a.ID, "%s", msg.Message)
+		}
+	}
+
+	// Watches are not recursive, so nested folders only clash when files
+	// are moved between them; the same folder under two names always does
+	real := make(map[string]string)
+	for _, path := range paths {
+		if path == "" || !filepath.IsAbs(path) {
+			add(IssueError, path, "", "path must be absolute")
+			continue
+		}
+		info, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err):
+			add(IssueError, path, "", "folder does not exist")
+			continue
+		case err != nil:
+			add(IssueError, path, "", "%v", err)
+			continue
+		case !info.IsDir():
+			add(IssueError, path, "", "not a directory")
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			real[path] = resolved
+		} else {
+			real[path] = path
+		}
+	}
+	// Reported on the folder added last, so AddFolder refuses it
+	for i, path := range paths {
+		for _, other := range paths[:i] {
+			if real[path] == "" || real[other] == "" {
+				continue
+			}
+			switch {
+			case real[path] == real[other]:
+				add(IssueError, path, "", "same folder as %s, files would be processed twice", other)
+			case strings.HasPrefix(real[path], real[other]+string(filepath.Separator)):
+				add(IssueWarning, path, "", "inside watched folder %s", other)
+			case strings.HasPrefix(real[other], real[path]+string(filepath.Separator)):
+				add(IssueWarning, other, "", "inside watched folder %s", path)
+			}
+		}
+	}
+
+	// An action writing into another watched folder hands files over to
+	// it; if that folder writes back, files go around forever
+	writesTo := make(map[string][]string)
+	for _, a := range cfg.Folders {
+		var written []string
+		for _, target := range commandTargets(a) {
+			for _, other := range paths {
+				if real[other] == "" || real[other] == real[a.Path] || slices.Contains(written, other) {
+					continue
+				}
+				if into(target, other) || into(target, real[other]) {
+					written = append(written, other)
+					add(IssueWarning, a.Path, a.ID, "writes into watched folder %s", other)
+				}
+			}
+		}
+		for _, other := range written {
+			if !slices.Contains(writesTo[a.Path], other) {
+				writesTo[a.Path] = append(writesTo[a.Path], other)
+			}
+		}
+	}
+	for _, from := range paths {
+		for _, to := range writesTo[from] {
+			if slices.Contains(writesTo[to], from) && from < to {
+				add(IssueError, from, "", "%s and %s write into each other, files would loop", from, to)
+			}
+		}
+	}
+
+	for _, s := range cfg.Settings {
+		if !slices.Contains(paths, s.Path) {
+			add(IssueWarning, s.Path, "", "settings for a folder with no actions")
+		}
+		if s.OriginalsMaxAge != "" {
+			if _, err := originals.ParseAge(s.OriginalsMaxAge); err != nil {
+				add(IssueError, s.Path, "", "originals_max_age: %v", err)
+			}
+		}
+		if s.OriginalsMaxSize != "" {
+			if _, err := originals.ParseSize(s.OriginalsMaxSize); err != nil {
+				add(IssueError, s.Path, "", "originals_max_size: %v", err)
+			}
+		}
+	}
+	return issues
+}
+
+// checkAction validates a single action; Path and ActionID are left empty
+func checkAction(a FolderAction) []Issue {
+	var issues []Issue
+	add := func(severity, format string, args ...any) {
+		issues = append(issues, Issue{Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if a.Action != "" && a.Command != "" {
+		add(IssueWarning, "has both action %s and a command, only the command runs", a.Action)
+	}
+	if a.NotifyLevel != "" {
+		if err := ValidateNotifyLevel(a.NotifyLevel); err != nil {
+			add(IssueError, "%v", err)
+		}
+	}
+
+	if a.Command != "" {
+		if err := presets.ValidatePlaceholders(a.Command); err != nil {
+			add(IssueError, "%v", err)
+		}
+		if rmOriginal.MatchString(a.Command) {
+			add(IssueWarning, "deletes the file for good; use gato trash {} so it can be restored (the daemon rewrites it when it loads the config)")
+		}
+		if missing := presets.MissingBinaries(a.Command); len(missing) > 0 {
+			add(IssueWarning, "not installed: %s", strings.Join(missing, ", "))
+		}
+		for _, target := range commandTargets(a) {
+			if reprocessed(a, target) {
+				add(IssueWarning, "creates %s, which this action processes again; limit it with extensions", filepath.Base(target))
+			}
+		}
+		return issues
+	}
+	if a.Action == "" {
+		return issues
+	}
+
+	if !IsPredefinedAction(a.Action) {
+		add(IssueError, "unknown action: %s", a.Action)
+		return issues
+	}
+	var missing []string
+	for _, bin := range predefinedBinaries[a.Action] {
+		if _, err := exec.LookPath(bin); err != nil {
+			missing = append(missing, bin)
+		}
+	}
+	if len(missing) > 0 {
+		add(IssueWarning, "not installed: %s", strings.Join(missing, ", "))
+	}
+	// The output of a conversion matching the action is converted onto
+	// itself and then moved to the Trash
+	if ext, ok := predefinedOutputs[a.Action]; ok && a.Matches("file"+ext) {
+		add(IssueWarning, "also matches the %s files it creates, which would end up in the Trash; limit it with extensions", ext)
+	}
+	return issues
+}
+
+// commandTargets returns the paths a custom command mentions besides {},
+// with {dir} replaced by the action's folder. A trailing slash marks a
+// directory the command writes into.
+func commandTargets(a FolderAction) []string {
+	var targets []string
+	for _, m := range outputPathRe.FindAllStringSubmatch(a.Command, -1) {
+		target := expandHome(strings.Replace(m[1], "{dir}", a.Path, 1))
+		if strings.HasSuffix(m[1], "/") {
+			target = filepath.Join(target, "{name}")
+		}
+		if !strings.Contains(filepath.Ext(target), "{") {
+			targets = append(targets, filepath.Clean(target))
+		}
+	}
+	return targets
+}
+
+// into reports whether target is folder itself or a file directly in it
+func into(target, folder string) bool {
+	return target == folder || filepath.Dir(target) == folder
+}
+
+// reprocessed reports whether a file a command creates in its own folder
+// would trigger the action again. Outputs named {name}.ext with a common
+// extension are ignored for a while by the watcher, see markOutputFiles.
+func reprocessed(a FolderAction, target string) bool {
+	ext := filepath.Ext(target)
+	if ext == "" || filepath.Dir(target) != a.Path || !a.Matches(target) {
+		return false
+	}
+	return filepath.Base(target) != "{name}"+ext || !slices.Contains(outputExtensions, ext)
+}
+
+// checkPresets reports user presets that are invalid or can never be used
+func checkPresets() []Issue {
+	store := presets.New()
+	if err := store.Load(); err != nil {
+		return []Issue{{Severity: IssueError, Message: err.Error()}}
+	}
+	var issues []Issue
+	for _, p := range store.User() {
+		if got, _ := store.Get(p.Name); got.Builtin {
+			issues = append(issues, Issue{Severity: IssueWarning, Message: fmt.Sprintf("preset %s is hidden by the builtin preset with the same name", p.Name)})
+			continue
+		}
+		if err := presets.ValidateCommand(p.Command, p.Params); err != nil {
+			issues = append(issues, Issue{Severity: IssueError, Message: fmt.Sprintf("preset %s: %v", p.Name, err)})
+		}
+	}
+	return issues
+}
@@ -0,0 +1,98 @@
+package folders
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckFileDoesNotWrite(t *testing.T) {
+	m := newTestManager(t)
+	folder := t.TempDir()
+	config := "[[folders]]\npath = '" + folder + "'\naction = 'shrink'\n"
+	os.MkdirAll(filepath.Dir(m.configPath), 0755)
+	if err := os.WriteFile(m.configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	issues := m.CheckFile()
+	if len(issues) != 1 || issues[0].Severity != IssueError || issues[0].Message != "unknown action: shrink" {
+		t.Errorf("issues = %v", issues)
+	}
+	if data, _ := os.ReadFile(m.configPath); string(data) != config {
+		t.Errorf("folders.toml changed to:\n%s", data)
+	}
+
+	os.WriteFile(m.configPath, []byte("[[folders]\n"), 0644)
+	if issues := m.CheckFile(); !HasErrors(issues) || !strings.Contains(issues[0].Message, m.configPath) {
+		t.Errorf("broken file issues = %v", issues)
+	}
+
+	os.Remove(m.configPath)
+	if issues := m.CheckFile(); len(issues) != 0 {
+		t.Errorf("missing file issues = %v", issues)
+	}
+	if _, err := os.Stat(m.configPath); !os.IsNotExist(err) {
+		t.Errorf("CheckFile created folders.toml")
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	nested := filepath.Join(a, "sub")
+	os.Mkdir(nested, 0755)
+	file := filepath.Join(a, "file.txt")
+	os.WriteFile(file, nil, 0644)
+	link := filepath.Join(t.TempDir(), "link")
+	os.Symlink(a, link)
+
+	tests := []struct {
+		name    string
+		folders []FolderAction
+		want    []string // "severity path: message" substrings, in order
+	}{
+		{"valid", []FolderAction{{ID: "a1", Path: a, Command: "true {}"}}, nil},
+		{"relative path", []FolderAction{{ID: "a1", Path: "Pictures", Command: "true {}"}},
+			[]string{"error Pictures: path must be absolute"}},
+		{"missing folder", []FolderAction{{ID: "a1", Path: "/no/such/folder", Command: "true {}"}},
+			[]string{"error /no/such/folder: folder does not exist"}},
+		{"not a directory", []FolderAction{{ID: "a1", Path: file, Command: "true {}"}},
+			[]string{"error " + file + ": not a directory"}},
+		{"duplicate ID", []FolderAction{{ID: "a1", Path: a, Command: "true {}"}, {ID: "a1", Path: b, Command: "true {}"}},
+			[]string{"error " + b + " [a1]: ID is used by more than one action"}},
+		{"unknown placeholder", []FolderAction{{ID: "a1", Path: a, Command: "cat {file}"}},
+			[]string{"error " + a + " [a1]: "}},
+		{"bad notify level", []FolderAction{{ID: "a1", Path: a, Command: "true {}", NotifyLevel: "loud"}},
+			[]string{"error " + a + " [a1]: "}},
+		{"deletes the file", []FolderAction{{ID: "a1", Path: a, Command: "true {} && rm {}"}},
+			[]string{"warning " + a + " [a1]: deletes the file for good"}},
+		{"action and command", []FolderAction{{ID: "a1", Path: a, Action: "compress", Command: "true {}"}},
+			[]string{"warning " + a + " [a1]: has both action compress and a command"}},
+		{"same folder twice", []FolderAction{{ID: "a1", Path: a, Command: "true {}"}, {ID: "b1", Path: link, Command: "true {}"}},
+			[]string{"error " + link + ": same folder as " + a}},
+		{"nested folder", []FolderAction{{ID: "a1", Path: a, Command: "true {}"}, {ID: "b1", Path: nested, Command: "true {}"}},
+			[]string{"warning " + nested + ": inside watched folder " + a}},
+		{"loop", []FolderAction{
+			{ID: "a1", Path: a, Command: "cp {} " + b + "/", Extensions: []string{"txt"}},
+			{ID: "b1", Path: b, Command: "cp {} " + a + "/", Extensions: []string{"txt"}},
+		}, []string{
+			"warning " + a + " [a1]: writes into watched folder " + b,
+			"warning " + b + " [b1]: writes into watched folder " + a,
+			"error " + min(a, b) + ": " + min(a, b) + " and " + max(a, b) + " write into each other",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := checkConfig(Config{Folders: tt.folders})
+			if len(issues) != len(tt.want) {
+				t.Fatalf("issues = %v, want %d", issues, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got := issues[i].Severity + " " + issues[i].String(); !strings.HasPrefix(got, want) {
+					t.Errorf("issue %d = %q, want prefix %q", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package folders
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentUpdates runs Update from several managers at once, as
+// separate gato processes would, and checks that no change is lost
+func TestConcurrentUpdates(t *testing.T) {
+	newTestManager(t)
+	folder := t.TempDir()
+
+	const writers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := New()
+			errs <- m.Update(func() error {
+				_, err := m.AddFolder(folder, "", fmt.Sprintf("echo %d {}", i), nil, false, "")
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := New()
+	if err := m.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(m.GetFolderActions(folder)); got != writers {
+		t.Errorf("%d actions saved, want %d", got, writers)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(m.configPath))
+	for _, e := range entries {
+		if e.Name() != "folders.toml" && e.Name() != "folders.toml.lock" {
+			t.Errorf("unexpected file next to the config: %s", e.Name())
+		}
+	}
+}
+
+func TestTrashOriginals(t *testing.T) {
+	m := newTestManager(t)
+	folder := t.TempDir()
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"convert {} {dir}/{name}.webp && rm {}", "convert {} {dir}/{name}.webp && gato trash {}"},
+		{"ffmpeg -i {} {dir}/{name}.mp3; rm -f {}", "ffmpeg -i {} {dir}/{name}.mp3; gato trash {}"},
+		{"(gzip -k {} && rm {}) || true", "(gzip -k {} && gato trash {}) || true"},
+		{"rm {}", "gato trash {}"},
+		{"convert {} {dir}/{name}.webp && gato trash {}", "convert {} {dir}/{name}.webp && gato trash {}"},
+		{"rm {dir}/{name}.tmp {}", "rm {dir}/{name}.tmp {}"},
+		{"echo confirm {}", "echo confirm {}"},
+	}
+	var config strings.Builder
+	for i, tt := range tests {
+		fmt.Fprintf(&config, "[[folders]]\nid = 'a%d'\npath = '%s'\ncommand = '%s'\n\n", i, folder, tt.command)
+	}
+	os.MkdirAll(filepath.Dir(m.configPath), 0755)
+	os.WriteFile(m.configPath, []byte(config.String()), 0644)
+
+	if err := m.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	// The rewritten commands are saved, so gato f check agrees with the daemon
+	saved := New()
+	if err := saved.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range tests {
+		for _, m := range []*Manager{m, saved} {
+			if a, _ := m.GetAction(fmt.Sprintf("a%d", i)); a.Command != tt.want {
+				t.Errorf("%q loaded as %q, want %q", tt.command, a.Command, tt.want)
+			}
+		}
+	}
+	data, _ := os.ReadFile(m.configPath)
+	if rmOriginal.Match(data) {
+		t.Errorf("folders.toml still deletes files:\n%s", data)
+	}
+}
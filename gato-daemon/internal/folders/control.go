@@ -0,0 +1,189 @@
+package folders
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/veinticinco/gato-daemon/internal/rpc"
+)
+
+// errStopped is returned for requests that arrive while the daemon shuts down
+var errStopped = errors.New("daemon is stopping")
+
+// do runs fn on the Start loop, which owns the config and the watchers
+func (m *Manager) do(fn func(ctx context.Context) error) error {
+	done := make(chan error, 1)
+	select {
+	case m.control <- func(ctx context.Context) { done <- fn(ctx) }:
+		return <-done
+	case <-m.stopped:
+		return errStopped
+	}
+}
+
+// reload rereads the config and recreates the watchers. Only called from
+// the Start loop.
+func (m *Manager) reload(ctx context.Context) error {
+	if err := m.LoadConfig(); err != nil {
+		m.reportConfig([]Issue{{Severity: IssueError, Message: err.Error()}})
+		return err
+	}
+	m.reportConfig(m.CheckConfig())
+	m.resume = m.refreshWatchers(ctx)
+	m.emit(Event{Kind: EventConfigChanged})
+	return nil
+}
+
+// Reload makes a running daemon pick up config changes right away
+func (m *Manager) Reload() error {
+	return m.do(func(ctx context.Context) error {
+		log.Println("Reload requested")
+		return m.reload(ctx)
+	})
+}
+
+// Apply runs a config change through Update on the Start loop and reloads,
+// so requests from the socket and D-Bus never race with the config watcher
+// or with other programs editing the file
+func (m *Manager) Apply(change func() error) error {
+	return m.do(func(ctx context.Context) error {
+		if err := m.Update(change); err != nil {
+			return err
+		}
+		return m.reload(ctx)
+	})
+}
+
+// Inspect runs fn on the Start loop so it sees a consistent config
+func (m *Manager) Inspect(fn func()) error {
+	return m.do(func(context.Context) error {
+		fn()
+		return nil
+	})
+}
+
+// Enqueue queues an existing file in a configured folder
+func (m *Manager) Enqueue(file string) (Job, error) {
+	var job Job
+	err := m.do(func(ctx context.Context) error {
+		file = expandHome(file)
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		folder := filepath.Dir(file)
+
+		actions := m.activeActions(folder)
+		if len(actions) == 0 {
+			return fmt.Errorf("no enabled actions for %s", folder)
+		}
+		queued := m.enqueue(ctx, file, folder, actions)
+		// A worker may already be running it
+		m.queue.mu.Lock()
+		job = *queued
+		m.queue.mu.Unlock()
+		return nil
+	})
+	return job, err
+}
+
+// Serve exposes the manager on the control socket: status, reload, pause,
+// resume, enqueue, run, jobs, cancel and the events subscription
+func (m *Manager) Serve(ctx context.Context, srv *rpc.Server) {
+	srv.Handle("status", func(json.RawMessage) (any, error) {
+		return m.Status(), nil
+	})
+	srv.Handle("reload", func(json.RawMessage) (any, error) {
+		return nil, m.Reload()
+	})
+	srv.Handle("pause", func(raw json.RawMessage) (any, error) {
+		var p struct {
+			Path  string    `json:"path"`
+			Until time.Time `json:"until"` // zero = until resumed
+		}
+		if err := rpc.Params(raw, &p); err != nil {
+			return nil, err
+		}
+		return nil, m.Apply(func() error { return m.PauseFolder(p.Path, p.Until) })
+	})
+	srv.Handle("resume", func(raw json.RawMessage) (any, error) {
+		var p struct {
+			Path string `json:"path"`
+		}
+		if err := rpc.Params(raw, &p); err != nil {
+			return nil, err
+		}
+		return nil, m.Apply(func() error { return m.ResumeFolder(p.Path) })
+	})
+	srv.Handle("enqueue", func(raw json.RawMessage) (any, error) {
+		var p struct {
+			File string `json:"file"`
+		}
+		if err := rpc.Params(raw, &p); err != nil {
+			return nil, err
+		}
+		return m.Enqueue(p.File)
+	})
+	srv.Handle("run", func(raw json.RawMessage) (any, error) {
+		var p struct {
+			Folder string `json:"folder"`
+			RunOptions
+		}
+		if err := rpc.Params(raw, &p); err != nil {
+			return nil, err
+		}
+		return m.RunFolder(p.Folder, p.RunOptions)
+	})
+	srv.Handle("jobs", func(json.RawMessage) (any, error) {
+		return m.Jobs(), nil
+	})
+	srv.HandleStream("events", func(ctx context.Context, raw json.RawMessage, send func(any) error) error {
+		var p struct {
+			Kinds []string `json:"kinds"` // empty = all
+		}
+		if len(raw) > 0 {
+			if err := rpc.Params(raw, &p); err != nil {
+				return err
+			}
+		}
+		events, stop := m.Subscribe()
+		defer stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case e := <-events:
+				if len(p.Kinds) > 0 && !slices.Contains(p.Kinds, e.Kind) {
+					continue
+				}
+				if err := send(e); err != nil {
+					return err
+				}
+			}
+		}
+	})
+	srv.Handle("cancel", func(raw json.RawMessage) (any, error) {
+		var p struct {
+			ID     string `json:"id"`
+			Folder string `json:"folder"`
+		}
+		if err := rpc.Params(raw, &p); err != nil {
+			return nil, err
+		}
+		if p.Folder != "" {
+			return m.CancelFolder(p.Folder)
+		}
+		job, err := m.CancelJob(p.ID)
+		if err != nil {
+			return nil, err
+		}
+		return []Job{job}, nil
+	})
+
+	srv.Serve(ctx)
+}
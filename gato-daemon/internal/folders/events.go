@@ -0,0 +1,94 @@
+package folders
+
+import (
+	"sync"
+	"time"
+)
+
+// Event kinds
+const (
+	EventJobQueued     = "job-queued"
+	EventJobStarted    = "job-started"
+	EventJobProgress   = "job-progress"
+	EventJobFinished   = "job-finished"
+	EventConfigChanged = "config-changed"
+	EventWatchError    = "watch-error"
+)
+
+// EventKinds lists every event kind
+var EventKinds = []string{EventJobQueued, EventJobStarted, EventJobProgress, EventJobFinished, EventConfigChanged, EventWatchError}
+
+// Event is something the daemon did, delivered to subscribers
+type Event struct {
+	Kind   string    `json:"kind"`
+	Time   time.Time `json:"time"`
+	Job    *Job      `json:"job,omitempty"`
+	Action string    `json:"action,omitempty"` // job-started: the action being run
+	Status string    `json:"status,omitempty"` // job-finished: ok, failed, cancelled
+	Error  string    `json:"error,omitempty"`  // job-finished, watch-error
+	Path   string    `json:"path,omitempty"`   // watch-error: the folder
+}
+
+// subscribers fans events out to listeners
+type subscribers struct {
+	mu   sync.Mutex
+	next int
+	subs map[int]chan Event
+}
+
+// Subscribe returns a channel of events and a function to stop receiving
+// them. Slow subscribers miss events rather than blocking the daemon.
+func (m *Manager) Subscribe() (<-chan Event, func()) {
+	s := &m.events
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subs == nil {
+		s.subs = make(map[int]chan Event)
+	}
+	id := s.next
+	s.next++
+	ch := make(chan Event, 64)
+	s.subs[id] = ch
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subs[id]; ok {
+			delete(s.subs, id)
+			close(ch)
+		}
+	}
+}
+
+// emit sends an event to every subscriber
+func (m *Manager) emit(e Event) {
+	e.Time = time.Now()
+	s := &m.events
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// jobEvent emits an event carrying a snapshot of job
+func (m *Manager) jobEvent(kind string, job *Job, e Event) {
+	m.queue.mu.Lock()
+	snapshot := *job
+	m.queue.mu.Unlock()
+	e.Kind = kind
+	e.Job = &snapshot
+	m.emit(e)
+}
+
+// setProgress records how far along a running job is, from 0 to 1
+func (m *Manager) setProgress(job *Job, progress float64) {
+	m.queue.mu.Lock()
+	job.Progress = progress
+	m.queue.mu.Unlock()
+	m.jobEvent(EventJobProgress, job, Event{})
+	m.statusChanged()
+}
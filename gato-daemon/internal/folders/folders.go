@@ -2,32 +2,60 @@ package folders
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/pelletier/go-toml/v2"
+	"github.com/veinticinco/gato-daemon/internal/fsutil"
+	"github.com/veinticinco/gato-daemon/internal/history"
+	"github.com/veinticinco/gato-daemon/internal/notify"
+	"github.com/veinticinco/gato-daemon/internal/trash"
 )
 
 // FolderAction defines what happens when a file is added to a folder
 type FolderAction struct {
-	Path         string   `toml:"path"`
-	Action       string   `toml:"action"`     // predefined: compress, convert-mp4, convert-webp, etc.
-	Command      string   `toml:"command"`    // custom command, {} = filename
-	Extensions   []string `toml:"extensions"` // only process these extensions (empty = all)
-	Notify       bool     `toml:"notify"`
-	KeepOriginal bool     `toml:"keep_original"`
+	ID           string   `toml:"id" json:"id"` // short stable identifier, see ids.go
+	Path         string   `toml:"path" json:"path"`
+	Action       string   `toml:"action" json:"action"`                       // predefined: compress, convert-mp4, convert-webp, etc.
+	Command      string   `toml:"command" json:"command"`                     // custom command, {} = filename
+	Extensions   []string `toml:"extensions" json:"extensions"`               // only process these extensions (empty = all)
+	Notify       bool     `toml:"notify" json:"notify"`                       // legacy, see NotifyMode
+	NotifyLevel  string   `toml:"notify_level,omitempty" json:"notify_level"` // never, failure, always, summary
+	KeepOriginal bool     `toml:"keep_original" json:"keep_original"`
+	Enabled      *bool    `toml:"enabled,omitempty" json:"enabled"` // nil = enabled, see IsEnabled
+}
+
+// MarshalJSON keeps the schema stable by always emitting extensions as a list,
+// enabled as a boolean and the effective notify level
+func (f FolderAction) MarshalJSON() ([]byte, error) {
+	type plain FolderAction
+	if f.Extensions == nil {
+		f.Extensions = []string{}
+	}
+	f.NotifyLevel = f.NotifyMode()
+	return json.Marshal(struct {
+		plain
+		Enabled bool `json:"enabled"`
+	}{plain(f), f.IsEnabled()})
 }
 
 // Config holds all folder configurations
 type Config struct {
-	Folders []FolderAction `toml:"folders"`
+	Folders  []FolderAction   `toml:"folders"`
+	Settings []FolderSettings `toml:"folder_settings,omitempty"`
 }
 
 // Manager handles intelligent folders
@@ -37,6 +65,18 @@ type Manager struct {
 	watchers      map[string]*fsnotify.Watcher
 	recentOutputs map[string]time.Time // Track output files to avoid reprocessing
 	outputMu      sync.Mutex
+	batches       batches          // pending per-folder notifications
+	events        subscribers      // see Subscribe
+	notifier      *notify.Notifier // nil = notify-send without actions
+	history       *history.Store   // processed files, used by undo
+	queue         queue            // files waiting to be processed
+	daemon        *daemonInfo      // nil outside the daemon, see EnableStatus
+	reported      string           // config issues last logged, see reportConfig
+	updating      bool             // inside Update, which does the write
+
+	control chan func(context.Context) // work for the Start loop, see do
+	stopped chan struct{}              // closed when Start returns
+	resume  <-chan time.Time           // fires when the next timed pause ends
 }
 
 // New creates a new folder manager
@@ -48,43 +88,141 @@ func New() *Manager {
 		configPath:    configPath,
 		watchers:      make(map[string]*fsnotify.Watcher),
 		recentOutputs: make(map[string]time.Time),
+		history:       history.New(StateDir()),
+		queue:         queue{jobs: make(map[string]*Job), wake: make(chan struct{}, 1)},
+		control:       make(chan func(context.Context)),
+		stopped:       make(chan struct{}),
 	}
 }
 
+// lockTimeout is how long writers wait for the config lock
+const lockTimeout = 10 * time.Second
+
 // LoadConfig reads the configuration file
 func (m *Manager) LoadConfig() error {
 	// Create config dir if needed
 	os.MkdirAll(filepath.Dir(m.configPath), 0755)
 
+	changed, err := m.readConfig()
+	if err != nil {
+		return err
+	}
+	rewritten := m.trashOriginals()
+	for _, f := range rewritten {
+		log.Printf("Config: %s [%s] now moves files to the Trash instead of deleting them", f.Path, f.ID)
+	}
+	if !changed && len(rewritten) == 0 {
+		return nil
+	}
+	// Write the default config, the new IDs or the rewritten commands back
+	return m.Update(func() error {
+		m.trashOriginals()
+		return nil
+	})
+}
+
+// readConfig parses the configuration file and assigns missing IDs. It
+// reports whether the result differs from the file. A broken file leaves
+// the previous config in place.
+func (m *Manager) readConfig() (changed bool, err error) {
 	data, err := os.ReadFile(m.configPath)
+	if os.IsNotExist(err) {
+		m.config = Config{Folders: []FolderAction{}}
+		return true, nil
+	}
 	if err != nil {
-		if os.IsNotExist(err) {
-			// Create default config
-			m.config = Config{Folders: []FolderAction{}}
-			return m.SaveConfig()
+		return false, err
+	}
+
+	var config Config
+	if err := toml.Unmarshal(data, &config); err != nil {
+		return false, fmt.Errorf("%s: %w", m.configPath, err)
+	}
+	m.config = config
+	return m.assignIDs(), nil
+}
+
+// rmOriginal matches a command deleting the file, as presets expanded into
+// folders.toml did before they used the Trash
+var rmOriginal = regexp.MustCompile(`(^|[\s;&|(])rm(?:\s+-f)?\s+\{\}(\s|[;&|)]|$)`)
+
+// trashOriginals rewrites "rm {}" in commands to "gato trash {}", so
+// originals deleted by older configs can be restored. Returns the actions
+// it changed.
+func (m *Manager) trashOriginals() []FolderAction {
+	var rewritten []FolderAction
+	for i, f := range m.config.Folders {
+		command := rmOriginal.ReplaceAllString(f.Command, "${1}gato trash {}${2}")
+		if command != f.Command {
+			m.config.Folders[i].Command = command
+			rewritten = append(rewritten, m.config.Folders[i])
 		}
+	}
+	return rewritten
+}
+
+// Update is the way to change the configuration: it takes the lock shared
+// by every program that writes folders.toml, rereads the file so changes
+// made by others since LoadConfig are kept, runs change and writes the
+// result once. Methods that save, like AddFolder, can be called from change.
+func (m *Manager) Update(change func() error) error {
+	os.MkdirAll(filepath.Dir(m.configPath), 0755)
+	unlock, err := m.lockConfig()
+	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	return toml.Unmarshal(data, &m.config)
+	if _, err := m.readConfig(); err != nil {
+		return err
+	}
+	m.updating = true
+	defer func() { m.updating = false }()
+	if err := change(); err != nil {
+		return err
+	}
+	return m.writeConfig()
 }
 
-// SaveConfig writes the configuration file
+// SaveConfig writes the configuration file. Inside Update the write is left
+// to Update; elsewhere the file is replaced as is, losing changes made by
+// others since it was read, so prefer Update.
 func (m *Manager) SaveConfig() error {
-	data, err := toml.Marshal(m.config)
+	if m.updating {
+		return nil
+	}
+	unlock, err := m.lockConfig()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(m.configPath, data, 0644)
+	defer unlock()
+	return m.writeConfig()
 }
 
-// AddFolder adds a new action to a folder (allows multiple actions per folder)
-func (m *Manager) AddFolder(path, action, command string, extensions []string, keepOriginal bool) error {
-	// Expand ~ to home directory
-	if strings.HasPrefix(path, "~/") {
-		homeDir, _ := os.UserHomeDir()
-		path = filepath.Join(homeDir, path[2:])
+// lockConfig takes the advisory lock on folders.toml.lock that the CLI,
+// the daemon and Gato Carpetas hold while they change the config
+func (m *Manager) lockConfig() (func(), error) {
+	unlock, err := fsutil.Lock(m.configPath+".lock", lockTimeout)
+	if errors.Is(err, fsutil.ErrLocked) {
+		return nil, fmt.Errorf("config is being changed by another program, try again")
 	}
+	return unlock, err
+}
+
+// writeConfig replaces the file atomically, so the daemon and the GUI
+// never read it half written
+func (m *Manager) writeConfig() error {
+	data, err := toml.Marshal(m.config)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(m.configPath, data, 0644)
+}
+
+// AddFolder adds a new action to a folder (allows multiple actions per folder).
+// An empty notifyLevel means NotifyAlways.
+func (m *Manager) AddFolder(path, action, command string, extensions []string, keepOriginal bool, notifyLevel string) (FolderAction, error) {
+	path = expandHome(path)
 
 	// Convert to absolute path
 	absPath, err := filepath.Abs(path)
@@ -94,69 +232,74 @@ func (m *Manager) AddFolder(path, action, command string, extensions []string, k
 
 	// Create folder if it doesn't exist
 	if err := os.MkdirAll(path, 0755); err != nil {
-		return fmt.Errorf("failed to create folder: %w", err)
+		return FolderAction{}, fmt.Errorf("failed to create folder: %w", err)
 	}
 
-	// Check if this exact action already exists for this path
-	for i, f := range m.config.Folders {
-		if f.Path == path && f.Action == action && f.Command == command {
-			// Update existing action entry
-			m.config.Folders[i] = FolderAction{
-				Path:         path,
-				Action:       action,
-				Command:      command,
-				Extensions:   extensions,
-				Notify:       true,
-				KeepOriginal: keepOriginal,
-			}
-			return m.SaveConfig()
-		}
+	if notifyLevel == "" {
+		notifyLevel = NotifyAlways
+	}
+	if err := ValidateNotifyLevel(notifyLevel); err != nil {
+		return FolderAction{}, err
 	}
 
-	// Add new action (even if path already has other actions)
-	m.config.Folders = append(m.config.Folders, FolderAction{
+	entry := FolderAction{
 		Path:         path,
 		Action:       action,
 		Command:      command,
 		Extensions:   extensions,
-		Notify:       true,
+		Notify:       notifyLevel != NotifyNever,
+		NotifyLevel:  notifyLevel,
 		KeepOriginal: keepOriginal,
+	}
+
+	// Update the entry if this exact action already exists for this path,
+	// otherwise add it (even if path already has other actions)
+	cfg := m.config
+	cfg.Folders = slices.Clone(m.config.Folders)
+	index := slices.IndexFunc(cfg.Folders, func(f FolderAction) bool {
+		return f.Path == path && f.Action == action && f.Command == command
 	})
+	if index >= 0 {
+		entry.ID = cfg.Folders[index].ID
+		cfg.Folders[index] = entry
+	} else {
+		entry.ID = m.newID()
+		cfg.Folders = append(cfg.Folders, entry)
+	}
 
-	return m.SaveConfig()
+	// Refuse changes that would break the folder; callers can show the
+	// warnings with CheckConfig
+	for _, issue := range checkConfig(cfg) {
+		if issue.Severity == IssueError && issue.Path == path && (issue.ActionID == "" || issue.ActionID == entry.ID) {
+			return FolderAction{}, errors.New(issue.Message)
+		}
+	}
+
+	m.config = cfg
+	return entry, m.SaveConfig()
 }
 
-// RemoveAction removes a specific action from a folder
+// RemoveAction removes a specific action from a folder and returns it
 // If command is provided, matches by command. If action is provided, matches by action.
-func (m *Manager) RemoveAction(path, action, command string) error {
-	if strings.HasPrefix(path, "~/") {
-		homeDir, _ := os.UserHomeDir()
-		path = filepath.Join(homeDir, path[2:])
-	}
+func (m *Manager) RemoveAction(path, action, command string) (FolderAction, error) {
+	path = expandHome(path)
 
 	for i, f := range m.config.Folders {
 		if f.Path != path {
 			continue
 		}
 		// Match by command if provided, otherwise by action
-		if command != "" && f.Command == command {
-			m.config.Folders = append(m.config.Folders[:i], m.config.Folders[i+1:]...)
-			return m.SaveConfig()
-		}
-		if action != "" && f.Action == action && command == "" {
+		if (command != "" && f.Command == command) || (action != "" && f.Action == action && command == "") {
 			m.config.Folders = append(m.config.Folders[:i], m.config.Folders[i+1:]...)
-			return m.SaveConfig()
+			return f, m.SaveConfig()
 		}
 	}
-	return fmt.Errorf("action not found")
+	return FolderAction{}, fmt.Errorf("action not found")
 }
 
 // GetFolderActions returns all actions for a specific folder path
 func (m *Manager) GetFolderActions(path string) []FolderAction {
-	if strings.HasPrefix(path, "~/") {
-		homeDir, _ := os.UserHomeDir()
-		path = filepath.Join(homeDir, path[2:])
-	}
+	path = expandHome(path)
 
 	var actions []FolderAction
 	for _, f := range m.config.Folders {
@@ -180,29 +323,26 @@ func (m *Manager) ListUniqueFolders() []string {
 	return paths
 }
 
-// RemoveFolder removes all actions for a folder
-func (m *Manager) RemoveFolder(path string) error {
-	if strings.HasPrefix(path, "~/") {
-		homeDir, _ := os.UserHomeDir()
-		path = filepath.Join(homeDir, path[2:])
-	}
+// RemoveFolder removes all actions for a folder and returns them
+func (m *Manager) RemoveFolder(path string) ([]FolderAction, error) {
+	path = expandHome(path)
 
-	found := false
-	var remaining []FolderAction
+	var remaining, removed []FolderAction
 	for _, f := range m.config.Folders {
 		if f.Path != path {
 			remaining = append(remaining, f)
 		} else {
-			found = true
+			removed = append(removed, f)
 		}
 	}
 
-	if !found {
-		return fmt.Errorf("folder not found: %s", path)
+	if len(removed) == 0 {
+		return nil, fmt.Errorf("folder not found: %s", path)
 	}
 
 	m.config.Folders = remaining
-	return m.SaveConfig()
+	m.removeSettings(path)
+	return removed, m.SaveConfig()
 }
 
 // ListFolders returns all configured folders
@@ -212,9 +352,11 @@ func (m *Manager) ListFolders() []FolderAction {
 
 // Start begins watching all configured folders and reloads on config changes
 func (m *Manager) Start(ctx context.Context) error {
+	defer close(m.stopped)
 	if err := m.LoadConfig(); err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	m.reportConfig(m.CheckConfig())
 
 	// Watch config file for changes
 	configWatcher, err := fsnotify.NewWatcher()
@@ -230,16 +372,33 @@ func (m *Manager) Start(ctx context.Context) error {
 	}
 
 	// Start watching folders
-	m.refreshWatchers(ctx)
+	m.startWorkers(ctx)
+	m.resume = m.refreshWatchers(ctx)
+
+	// The CLI reads the whole history for undo and gato f run, so keep it short
+	m.pruneHistory()
+	pruneTicker := time.NewTicker(pruneHistoryEvery)
+	defer pruneTicker.Stop()
 
 	// Main loop
 	for {
 		select {
+		case <-pruneTicker.C:
+			m.pruneHistory()
+
+		case <-m.resume:
+			log.Println("Pause expired, resuming...")
+			m.resume = m.refreshWatchers(ctx)
+
+		case fn := <-m.control:
+			fn(ctx)
+
 		case <-ctx.Done():
 			// Cleanup
 			for _, w := range m.watchers {
 				w.Close()
 			}
+			m.removeStatus()
 			return nil
 
 		case event, ok := <-configWatcher.Events:
@@ -250,12 +409,9 @@ func (m *Manager) Start(ctx context.Context) error {
 			if filepath.Base(event.Name) == "folders.toml" {
 				if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
 					log.Println("Config changed, reloading...")
-					time.Sleep(100 * time.Millisecond) // Wait for write to complete
-					if err := m.LoadConfig(); err != nil {
+					if err := m.reload(ctx); err != nil {
 						log.Printf("Failed to reload config: %v", err)
-						continue
 					}
-					m.refreshWatchers(ctx)
 				}
 			}
 
@@ -267,32 +423,75 @@ func (m *Manager) Start(ctx context.Context) error {
 	}
 }
 
-// refreshWatchers stops old watchers and starts new ones based on current config
-func (m *Manager) refreshWatchers(ctx context.Context) {
+// pruneHistoryEvery is how often the daemon applies the history retention
+const pruneHistoryEvery = 24 * time.Hour
+
+func (m *Manager) pruneHistory() {
+	removed, err := m.history.Prune()
+	if err != nil {
+		log.Printf("Warning: cannot prune history: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("Pruned %d old %s from history", removed, plural(removed, "job", "jobs"))
+	}
+}
+
+// refreshWatchers stops old watchers and starts new ones based on current config.
+// Paused folders and disabled actions are skipped. The returned channel fires
+// when the next timed pause ends (nil if there is none).
+func (m *Manager) refreshWatchers(ctx context.Context) <-chan time.Time {
 	// Close existing watchers
 	for path, w := range m.watchers {
 		w.Close()
 		delete(m.watchers, path)
 	}
+	m.resetWatches()
+	defer m.statusChanged()
+
+	var resume <-chan time.Time
+	if next := m.nextResume(); !next.IsZero() {
+		resume = time.After(time.Until(next))
+	}
 
 	if len(m.config.Folders) == 0 {
 		log.Println("No intelligent folders configured.")
-		return
+		return resume
 	}
 
 	// Start new watchers
 	for _, path := range m.ListUniqueFolders() {
-		actions := m.GetFolderActions(path)
+		if !m.FolderEnabled(path) {
+			log.Printf("Paused: %s", path)
+			w := WatchStatus{Path: path, State: WatchPaused}
+			if until := m.FolderSettings(path).PausedUntil; !until.IsZero() {
+				w.PausedUntil = &until
+			}
+			m.setWatch(w)
+			continue
+		}
+
+		actions := m.activeActions(path)
+		if len(actions) == 0 {
+			m.setWatch(WatchStatus{Path: path, State: WatchIdle})
+			continue
+		}
+
 		if err := m.watchFolder(ctx, path, actions); err != nil {
 			log.Printf("Warning: failed to watch %s: %v", path, err)
+			now := time.Now()
+			m.setWatch(WatchStatus{Path: path, State: WatchError, Actions: len(actions), LastError: err.Error(), ErrorTime: &now})
+			m.emit(Event{Kind: EventWatchError, Path: path, Error: err.Error()})
 			continue
 		}
+		m.setWatch(WatchStatus{Path: path, State: WatchOK, Actions: len(actions)})
 		var actionNames []string
 		for _, a := range actions {
 			actionNames = append(actionNames, m.describeAction(a))
 		}
 		log.Printf("Watching: %s -> [%s]", path, strings.Join(actionNames, ", "))
 	}
+	return resume
 }
 
 func (m *Manager) describeAction(f FolderAction) string {
@@ -327,6 +526,11 @@ func (m *Manager) watchFolder(ctx context.Context, path string, actions []Folder
 				if event.Op&fsnotify.Create == fsnotify.Create {
 					filePath := event.Name
 
+					// Hidden files, like .originals, are never processed
+					if strings.HasPrefix(filepath.Base(filePath), ".") {
+						continue
+					}
+
 					// Check if this is a recent output file (avoid reprocessing)
 					m.outputMu.Lock()
 					if t, exists := m.recentOutputs[filePath]; exists && time.Since(t) < 10*time.Second {
@@ -335,21 +539,19 @@ func (m *Manager) watchFolder(ctx context.Context, path string, actions []Folder
 					}
 					m.outputMu.Unlock()
 
-					// Process in goroutine for parallel handling
-					go func(filePath string, actions []FolderAction) {
-						// Wait for file to be fully written
-						time.Sleep(500 * time.Millisecond)
-						// Process through all actions
-						for _, action := range actions {
-							m.processFile(filePath, action)
-						}
-					}(filePath, actions)
+					// Files restored by gato f undo
+					if m.history.Suppressed(filePath) {
+						continue
+					}
+
+					m.enqueue(ctx, filePath, path, actions)
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
 				}
 				log.Printf("Watch error: %v", err)
+				m.watchError(path, err)
 			}
 		}
 	}()
@@ -357,67 +559,156 @@ func (m *Manager) watchFolder(ctx context.Context, path string, actions []Folder
 	return nil
 }
 
-func (m *Manager) processFile(filePath string, folder FolderAction) {
+// run is one file going through all of a folder's actions
+type run struct {
+	id     string
+	backup string // original kept by the first action that asked for one
+}
+
+// processFile runs one action on a file. Skipped files return nil.
+func (m *Manager) processFile(ctx context.Context, filePath string, folder FolderAction, r *run) error {
 	// Skip directories
 	info, err := os.Stat(filePath)
 	if err != nil || info.IsDir() {
-		return
+		return nil
 	}
 
 	// Skip hidden files and .originals
 	base := filepath.Base(filePath)
 	if strings.HasPrefix(base, ".") {
-		return
+		return nil
 	}
 
 	// Check extension filter
-	if len(folder.Extensions) > 0 {
-		ext := strings.ToLower(filepath.Ext(filePath))
-		matched := false
-		for _, e := range folder.Extensions {
-			if ext == "."+strings.TrimPrefix(e, ".") {
-				matched = true
-				break
-			}
-		}
-		if !matched {
-			return
-		}
+	if !folder.Matches(filePath) {
+		return nil
 	}
 
 	log.Printf("Processing: %s", filePath)
 
-	// Backup original if requested
-	if folder.KeepOriginal {
-		backupDir := filepath.Join(folder.Path, ".originals")
-		os.MkdirAll(backupDir, 0755)
-		backupPath := filepath.Join(backupDir, base)
-		copyFile(filePath, backupPath)
+	// Backup original if requested. Later actions in the same run would
+	// otherwise store an already processed file.
+	if folder.KeepOriginal && r.backup == "" {
+		r.backup, err = m.keepOriginal(folder.Path, filePath)
+		if err != nil {
+			log.Printf("Warning: cannot keep original of %s: %v", filePath, err)
+		}
 	}
 
-	// Execute action
-	var cmdErr error
-	if folder.Command != "" {
-		cmdErr = m.runCustomCommand(filePath, folder.Command)
-	} else {
-		cmdErr = m.runPredefinedAction(filePath, folder.Action)
+	job := history.Job{
+		ID:       history.NewID(),
+		Run:      r.id,
+		File:     filePath,
+		Folder:   folder.Path,
+		ActionID: folder.ID,
+		Action:   m.describeAction(folder),
+		Start:    time.Now(),
+		Before:   info.Size(),
+		Backup:   r.backup,
 	}
+	before := snapshotDir(filepath.Dir(filePath))
+
+	// Execute action
+	cmdErr := m.runAction(withTrashed(ctx, &job.Trashed), filePath, folder)
 
+	if ctx.Err() != nil {
+		cmdErr = ErrCancelled
+	}
+	job.End = time.Now()
+	job.Outputs, job.Replaced = before.diff(filePath)
+	if cmdErr == ErrCancelled {
+		job.Cancelled = true
+		m.discardPartial(&job)
+	}
+	job.After = totalSize(append([]string{filePath}, job.Outputs...))
 	if cmdErr != nil {
-		log.Printf("Action failed for %s: %v", filePath, cmdErr)
-		if folder.Notify {
-			notify("Gato", fmt.Sprintf("Failed: %s", base))
+		job.Error = cmdErr.Error()
+		job.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(cmdErr, &exitErr) {
+			job.ExitCode = exitErr.ExitCode()
 		}
-		return
+		log.Printf("Action failed for %s: %v", filePath, cmdErr)
+	} else {
+		log.Printf("Processed: %s", filePath)
 	}
+	if err := m.history.Add(job); err != nil {
+		log.Printf("Warning: cannot record history: %v", err)
+	}
+	if cmdErr != ErrCancelled {
+		m.notifyResult(folder, job, cmdErr)
+	}
+	return cmdErr
+}
+
+// killDelay is how long a cancelled command gets to exit after SIGTERM
+// before its process group is killed
+const killDelay = 5 * time.Second
+
+// groupCommand runs name in its own process group, so cancelling ctx also stops
+// whatever it started, such as the programs in a shell command
+func groupCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		pgid := cmd.Process.Pid
+		time.AfterFunc(killDelay, func() { syscall.Kill(-pgid, syscall.SIGKILL) })
+		return syscall.Kill(-pgid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = killDelay
+	if w := outputFrom(ctx); w != nil {
+		cmd.Stdout, cmd.Stderr = w, w
+	}
+	if dir := dataHomeFrom(ctx); dir != "" {
+		cmd.Env = append(os.Environ(), "XDG_DATA_HOME="+dir)
+	}
+	return cmd
+}
+
+type outputKey struct{}
+
+// withOutput sends the stdout and stderr of commands run under ctx to w
+func withOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+// outputFrom returns the writer set with withOutput, or nil
+func outputFrom(ctx context.Context) io.Writer {
+	w, _ := ctx.Value(outputKey{}).(io.Writer)
+	return w
+}
+
+type dataHomeKey struct{}
 
-	log.Printf("Processed: %s", filePath)
-	if folder.Notify {
-		notify("Gato", fmt.Sprintf("Processed: %s", base))
+// withDataHome makes actions run under ctx use dir instead of
+// $XDG_DATA_HOME, so what they move to the Trash lands in dir/Trash
+func withDataHome(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, dataHomeKey{}, dir)
+}
+
+// dataHomeFrom returns the directory set with withDataHome, or ""
+func dataHomeFrom(ctx context.Context) string {
+	dir, _ := ctx.Value(dataHomeKey{}).(string)
+	return dir
+}
+
+type trashedKey struct{}
+
+// withTrashed makes trashOriginal store where it moved the file in *dest,
+// so undo can bring it back
+func withTrashed(ctx context.Context, dest *string) context.Context {
+	return context.WithValue(ctx, trashedKey{}, dest)
+}
+
+// runAction runs an action's command or predefined action on a file
+func (m *Manager) runAction(ctx context.Context, filePath string, folder FolderAction) error {
+	if folder.Command != "" {
+		return m.runCustomCommand(ctx, filePath, folder.Command)
 	}
+	return m.runPredefinedAction(ctx, filePath, folder.Action)
 }
 
-func (m *Manager) runCustomCommand(filePath, command string) error {
+func (m *Manager) runCustomCommand(ctx context.Context, filePath, command string) error {
 	// Replace {} with the file path
 	cmd := strings.ReplaceAll(command, "{}", fmt.Sprintf("%q", filePath))
 
@@ -435,18 +726,59 @@ func (m *Manager) runCustomCommand(filePath, command string) error {
 	// Look for patterns like dir/name.ext in the expanded command
 	m.markOutputFiles(dir, name, command)
 
-	return exec.Command("bash", "-c", cmd).Run()
+	progressCmd, progress := withFFmpegProgress(cmd)
+	if progress {
+		cmd = progressCmd
+	}
+	c := groupCommand(ctx, "bash", "-c", cmd)
+	defer reportTrashed(ctx, c, filePath)()
+	if progress {
+		return runWithProgress(ctx, c, filePath)
+	}
+	return c.Run()
+}
+
+// reportTrashed points gato trash, when cmd runs it, at a report file, and
+// returns a function that reads back where filePath went once cmd is done.
+// This is trashOriginal's withTrashed for commands like the presets.
+func reportTrashed(ctx context.Context, cmd *exec.Cmd, filePath string) func() {
+	dest, ok := ctx.Value(trashedKey{}).(*string)
+	if !ok {
+		return func() {}
+	}
+	f, err := os.CreateTemp("", "gato-trash-*")
+	if err != nil {
+		log.Printf("Warning: cannot record where %s is trashed: %v", filePath, err)
+		return func() {}
+	}
+	f.Close()
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, trash.ReportEnv+"="+f.Name())
+
+	return func() {
+		defer os.Remove(f.Name())
+		trashed, err := trash.ReadReport(f.Name())
+		if err != nil {
+			log.Printf("Warning: cannot read where %s is trashed: %v", filePath, err)
+			return
+		}
+		if abs, err := filepath.Abs(filePath); err == nil && trashed[abs] != "" {
+			*dest = trashed[abs]
+		}
+	}
 }
 
+// outputExtensions are the extensions markOutputFiles looks for in commands
+var outputExtensions = []string{".webp", ".png", ".jpg", ".jpeg", ".mp4", ".mp3", ".gif", ".mov", ".avi", ".mkv"}
+
 // markOutputFiles registers potential output files to avoid reprocessing them
 func (m *Manager) markOutputFiles(dir, name, command string) {
-	// Common output extensions from the command
-	extensions := []string{".webp", ".png", ".jpg", ".jpeg", ".mp4", ".mp3", ".gif", ".mov", ".avi", ".mkv"}
-
 	m.outputMu.Lock()
 	defer m.outputMu.Unlock()
 
-	for _, ext := range extensions {
+	for _, ext := range outputExtensions {
 		if strings.Contains(command, ext) {
 			outputPath := filepath.Join(dir, name+ext)
 			m.recentOutputs[outputPath] = time.Now()
@@ -461,82 +793,135 @@ func (m *Manager) markOutputFiles(dir, name, command string) {
 	}
 }
 
-func (m *Manager) runPredefinedAction(filePath, action string) error {
+// PredefinedAction describes an action handled by the daemon itself
+type PredefinedAction struct {
+	Name        string
+	Description string
+}
+
+// PredefinedActions lists the actions understood by runPredefinedAction
+var PredefinedActions = []PredefinedAction{
+	{"compress", "Compress PNG/JPG/WebP in place"},
+	{"convert-webp", "Convert images to WebP"},
+	{"convert-mp4", "Convert videos to MP4"},
+	{"convert-mp3", "Convert audio to MP3"},
+	{"resize-50", "Resize images to 50%"},
+	{"resize-25", "Resize images to 25%"},
+}
+
+// IsPredefinedAction reports whether name is a known predefined action
+func IsPredefinedAction(name string) bool {
+	for _, a := range PredefinedActions {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) runPredefinedAction(ctx context.Context, filePath, action string) error {
 	ext := strings.ToLower(filepath.Ext(filePath))
 
 	switch action {
 	case "compress":
-		return m.compressFile(filePath, ext)
+		return m.compressFile(ctx, filePath, ext)
 	case "convert-webp":
-		return m.convertToWebP(filePath)
+		return m.convertToWebP(ctx, filePath)
 	case "convert-mp4":
-		return m.convertToMP4(filePath)
+		return m.convertToMP4(ctx, filePath)
 	case "convert-mp3":
-		return m.convertToMP3(filePath)
+		return m.convertToMP3(ctx, filePath)
 	case "resize-50":
-		return m.resizeImage(filePath, "50%")
+		return m.resizeImage(ctx, filePath, "50%")
 	case "resize-25":
-		return m.resizeImage(filePath, "25%")
+		return m.resizeImage(ctx, filePath, "25%")
 	default:
 		return fmt.Errorf("unknown action: %s", action)
 	}
 }
 
-func (m *Manager) compressFile(filePath, ext string) error {
+func (m *Manager) compressFile(ctx context.Context, filePath, ext string) error {
 	switch ext {
 	case ".png":
 		if _, err := exec.LookPath("pngquant"); err == nil {
-			return exec.Command("pngquant", "--force", "--quality=65-80", "--output", filePath, filePath).Run()
+			return groupCommand(ctx, "pngquant", "--force", "--quality=65-80", "--output", filePath, filePath).Run()
 		}
-		return exec.Command("convert", filePath, "-strip", "-colors", "256", filePath).Run()
+		return groupCommand(ctx, "convert", filePath, "-strip", "-colors", "256", filePath).Run()
 	case ".jpg", ".jpeg":
-		return exec.Command("convert", filePath, "-strip", "-quality", "75", filePath).Run()
+		return groupCommand(ctx, "convert", filePath, "-strip", "-quality", "75", filePath).Run()
 	case ".webp":
-		return exec.Command("convert", filePath, "-strip", "-quality", "75", filePath).Run()
+		return groupCommand(ctx, "convert", filePath, "-strip", "-quality", "75", filePath).Run()
 	default:
 		return nil // Skip unsupported formats
 	}
 }
 
-func (m *Manager) convertToWebP(filePath string) error {
+func (m *Manager) convertToWebP(ctx context.Context, filePath string) error {
 	output := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".webp"
-	err := exec.Command("convert", filePath, "-quality", "80", output).Run()
+	err := groupCommand(ctx, "convert", filePath, "-quality", "80", output).Run()
 	if err == nil {
-		os.Remove(filePath) // Remove original
+		m.trashOriginal(ctx, filePath)
 	}
 	return err
 }
 
-func (m *Manager) convertToMP4(filePath string) error {
+func (m *Manager) convertToMP4(ctx context.Context, filePath string) error {
 	output := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".mp4"
-	err := exec.Command("ffmpeg", "-i", filePath, "-c:v", "libx264", "-c:a", "aac", "-y", output).Run()
+	args := slices.Concat(ffmpegProgressArgs, []string{"-i", filePath, "-c:v", "libx264", "-c:a", "aac", "-y", output})
+	err := runWithProgress(ctx, groupCommand(ctx, "ffmpeg", args...), filePath)
 	if err == nil {
-		os.Remove(filePath)
+		m.trashOriginal(ctx, filePath)
 	}
 	return err
 }
 
-func (m *Manager) convertToMP3(filePath string) error {
+func (m *Manager) convertToMP3(ctx context.Context, filePath string) error {
 	output := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".mp3"
-	err := exec.Command("ffmpeg", "-i", filePath, "-c:a", "libmp3lame", "-q:a", "2", "-y", output).Run()
+	args := slices.Concat(ffmpegProgressArgs, []string{"-i", filePath, "-c:a", "libmp3lame", "-q:a", "2", "-y", output})
+	err := runWithProgress(ctx, groupCommand(ctx, "ffmpeg", args...), filePath)
 	if err == nil {
-		os.Remove(filePath)
+		m.trashOriginal(ctx, filePath)
 	}
 	return err
 }
 
-func (m *Manager) resizeImage(filePath, size string) error {
-	return exec.Command("convert", filePath, "-resize", size, filePath).Run()
+func (m *Manager) resizeImage(ctx context.Context, filePath, size string) error {
+	return groupCommand(ctx, "convert", filePath, "-resize", size, filePath).Run()
 }
 
-func copyFile(src, dst string) error {
-	data, err := os.ReadFile(src)
+// totalSize adds up the sizes of the files that exist
+func totalSize(paths []string) int64 {
+	var total int64
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// trashOriginal moves a converted file to the trash. If that fails the
+// original is left in place rather than deleted.
+func (m *Manager) trashOriginal(ctx context.Context, filePath string) {
+	put := trash.Put
+	if dir := dataHomeFrom(ctx); dir != "" {
+		put = func(path string) (string, error) { return trash.PutIn(path, filepath.Join(dir, "Trash")) }
+	}
+	trashed, err := put(filePath)
 	if err != nil {
-		return err
+		log.Printf("Warning: cannot move %s to trash: %v", filePath, err)
+		return
+	}
+	if dest, ok := ctx.Value(trashedKey{}).(*string); ok {
+		*dest = trashed
 	}
-	return os.WriteFile(dst, data, 0644)
 }
 
-func notify(title, message string) {
-	exec.Command("notify-send", title, message).Run()
+// expandHome expands a leading ~/ to the home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		homeDir, _ := os.UserHomeDir()
+		path = filepath.Join(homeDir, path[2:])
+	}
+	return path
 }
@@ -0,0 +1,142 @@
+package folders
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// newID returns a short random ID not used by any action in the config
+func (m *Manager) newID() string {
+	for {
+		b := make([]byte, 3)
+		rand.Read(b)
+		id := hex.EncodeToString(b)
+		if _, err := m.indexOf(id); err != nil {
+			return id
+		}
+	}
+}
+
+// assignIDs gives an ID to every action that lacks one (configs written
+// before IDs existed). Returns true if anything changed.
+func (m *Manager) assignIDs() bool {
+	changed := false
+	for i := range m.config.Folders {
+		if m.config.Folders[i].ID == "" {
+			m.config.Folders[i].ID = m.newID()
+			changed = true
+		}
+	}
+	return changed
+}
+
+// indexOf finds an action by ID or unique ID prefix
+func (m *Manager) indexOf(id string) (int, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == "" {
+		return -1, fmt.Errorf("empty action id")
+	}
+
+	found := -1
+	for i, f := range m.config.Folders {
+		if f.ID == id {
+			return i, nil
+		}
+		if strings.HasPrefix(f.ID, id) {
+			if found >= 0 {
+				return -1, fmt.Errorf("ambiguous action id: %s", id)
+			}
+			found = i
+		}
+	}
+	if found < 0 {
+		return -1, fmt.Errorf("action not found: %s", id)
+	}
+	return found, nil
+}
+
+// GetAction returns the action with the given ID (or unique prefix)
+func (m *Manager) GetAction(id string) (FolderAction, error) {
+	i, err := m.indexOf(id)
+	if err != nil {
+		return FolderAction{}, err
+	}
+	return m.config.Folders[i], nil
+}
+
+// RemoveActionByID removes an action by ID and returns it
+func (m *Manager) RemoveActionByID(id string) (FolderAction, error) {
+	i, err := m.indexOf(id)
+	if err != nil {
+		return FolderAction{}, err
+	}
+	f := m.config.Folders[i]
+	m.config.Folders = append(m.config.Folders[:i], m.config.Folders[i+1:]...)
+	return f, m.SaveConfig()
+}
+
+// UpdateAction applies edit to an action and saves. The path and ID cannot change.
+func (m *Manager) UpdateAction(id string, edit func(*FolderAction)) (FolderAction, error) {
+	i, err := m.indexOf(id)
+	if err != nil {
+		return FolderAction{}, err
+	}
+
+	f := m.config.Folders[i]
+	edit(&f)
+	f.ID = m.config.Folders[i].ID
+	f.Path = m.config.Folders[i].Path
+
+	for j, other := range m.config.Folders {
+		if j != i && other.Path == f.Path && other.Action == f.Action && other.Command == f.Command {
+			return FolderAction{}, fmt.Errorf("folder already has this action (%s)", other.ID)
+		}
+	}
+
+	m.config.Folders[i] = f
+	return f, m.SaveConfig()
+}
+
+// MoveAction moves an action so it runs right before (or after) another
+// action of the same folder
+func (m *Manager) MoveAction(id, targetID string, after bool) error {
+	from, err := m.indexOf(id)
+	if err != nil {
+		return err
+	}
+	to, err := m.indexOf(targetID)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return nil
+	}
+
+	moving := m.config.Folders[from]
+	if moving.Path != m.config.Folders[to].Path {
+		return fmt.Errorf("actions %s and %s belong to different folders", moving.ID, m.config.Folders[to].ID)
+	}
+	targetID = m.config.Folders[to].ID
+
+	// Remove, then reinsert relative to the target's new position
+	rest := append(m.config.Folders[:from:from], m.config.Folders[from+1:]...)
+	pos := 0
+	for i, f := range rest {
+		if f.ID == targetID {
+			pos = i
+			break
+		}
+	}
+	if after {
+		pos++
+	}
+
+	folders := make([]FolderAction, 0, len(m.config.Folders))
+	folders = append(folders, rest[:pos]...)
+	folders = append(folders, moving)
+	folders = append(folders, rest[pos:]...)
+	m.config.Folders = folders
+	return m.SaveConfig()
+}
@@ -0,0 +1,337 @@
+package folders
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/veinticinco/gato-daemon/internal/history"
+	"github.com/veinticinco/gato-daemon/internal/notify"
+)
+
+// Notification levels for an action
+const (
+	NotifyNever   = "never"   // no notifications
+	NotifyFailure = "failure" // only when the action fails
+	NotifyAlways  = "always"  // every file; bursts are batched into one notification
+	NotifySummary = "summary" // one notification per burst of files
+)
+
+// NotifyLevels lists the valid notification levels
+var NotifyLevels = []string{NotifyNever, NotifyFailure, NotifyAlways, NotifySummary}
+
+// batchWindow is how long a folder must stay quiet before its notification
+// is sent; batchMaxDelay caps the wait during long bursts
+const (
+	batchWindow   = 3 * time.Second
+	batchMaxDelay = 30 * time.Second
+)
+
+// ValidateNotifyLevel checks a level given on the command line or in the config
+func ValidateNotifyLevel(level string) error {
+	for _, l := range NotifyLevels {
+		if level == l {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown notify level: %s (available: %s)", level, strings.Join(NotifyLevels, ", "))
+}
+
+// NotifyMode returns the effective notification level. Configs written before
+// notify_level existed only have the notify boolean.
+func (f FolderAction) NotifyMode() string {
+	if f.NotifyLevel != "" {
+		return f.NotifyLevel
+	}
+	if f.Notify {
+		return NotifyAlways
+	}
+	return NotifyNever
+}
+
+// batchResult is one file's run through a folder's actions, waiting to be
+// notified
+type batchResult struct {
+	file     string
+	run      string // history run, for Undo
+	kept     bool   // an original was kept or trashed
+	replaced bool   // an action changed the file in place
+	added    bool   // an action wrote new files
+	err      error  // every failed action's error
+}
+
+// undoable reports whether Undo can put the file back as it was
+func (r batchResult) undoable() bool {
+	return r.kept || (!r.replaced && r.added)
+}
+
+// batch collects a folder's results until its notification is sent
+type batch struct {
+	results []batchResult
+	summary bool // at least one action asked for summary-only notifications
+	started time.Time
+	timer   *time.Timer
+}
+
+// count returns how many distinct files the batch holds and how many of
+// them failed. A file dropped again while its batch is open has several runs.
+func (b *batch) count() (files, failed int) {
+	seen := make(map[string]bool)
+	for _, r := range b.results {
+		if _, ok := seen[r.file]; !ok {
+			files++
+		}
+		if r.err != nil && !seen[r.file] {
+			failed++
+		}
+		seen[r.file] = seen[r.file] || r.err != nil
+	}
+	return files, failed
+}
+
+type batches struct {
+	mu      sync.Mutex
+	pending map[string]*batch
+}
+
+// notifyResult queues the notification for one processed file. Results for
+// the same folder are batched so bursts produce a single notification.
+func (m *Manager) notifyResult(folder FolderAction, job history.Job, err error) {
+	switch folder.NotifyMode() {
+	case NotifyAlways, NotifySummary:
+	case NotifyFailure:
+		if err == nil {
+			return
+		}
+	default:
+		return
+	}
+
+	m.batches.mu.Lock()
+	defer m.batches.mu.Unlock()
+
+	if m.batches.pending == nil {
+		m.batches.pending = make(map[string]*batch)
+	}
+	path := folder.Path
+	b, ok := m.batches.pending[path]
+	if !ok {
+		b = &batch{started: time.Now()}
+		m.batches.pending[path] = b
+		b.timer = time.AfterFunc(batchWindow, func() { m.flushBatch(path) })
+	} else if time.Since(b.started) < batchMaxDelay {
+		b.timer.Reset(batchWindow)
+	}
+
+	// Every action of a run reports here; they make up one result
+	i := slices.IndexFunc(b.results, func(r batchResult) bool { return r.run == job.Run })
+	if i < 0 {
+		b.results = append(b.results, batchResult{file: job.File, run: job.Run})
+		i = len(b.results) - 1
+	}
+	r := &b.results[i]
+	r.kept = r.kept || job.Backup != "" || job.Trashed != ""
+	r.replaced = r.replaced || job.Replaced
+	r.added = r.added || len(job.Outputs) > 0
+	r.err = errors.Join(r.err, err)
+	if folder.NotifyMode() == NotifySummary {
+		b.summary = true
+	}
+}
+
+func (m *Manager) flushBatch(path string) {
+	m.batches.mu.Lock()
+	b, ok := m.batches.pending[path]
+	delete(m.batches.pending, path)
+	m.batches.mu.Unlock()
+	if !ok || len(b.results) == 0 {
+		return
+	}
+
+	// A single file reads better as its own notification
+	files, failed := b.count()
+	if files == 1 && !b.summary {
+		m.notifyFile(b.results[len(b.results)-1])
+		return
+	}
+
+	note := notify.Notification{
+		Summary: summaryText(filepath.Base(path), files-failed, failed),
+		Actions: []notify.Action{
+			{Key: "folder", Label: "Show in folder", Run: func() { notify.Open(path) }},
+			{Key: "log", Label: "View log", Run: func() { notify.Open(LogPath()) }},
+		},
+	}
+
+	details, err := writeBatchDetails(path, b.results)
+	if err != nil {
+		log.Printf("Warning: cannot write notification details: %v", err)
+	} else {
+		note.Body = "Click for details"
+		note.Actions = append([]notify.Action{
+			{Key: "default", Label: "Show details", Run: func() { notify.Open(details) }},
+		}, note.Actions...)
+	}
+	m.send(note)
+}
+
+// flushNotifications sends every batched notification now, for processes
+// that exit before the batch window closes
+func (m *Manager) flushNotifications() {
+	m.batches.mu.Lock()
+	var paths []string
+	for path, b := range m.batches.pending {
+		b.timer.Stop()
+		paths = append(paths, path)
+	}
+	m.batches.mu.Unlock()
+	for _, path := range paths {
+		m.flushBatch(path)
+	}
+}
+
+// notifyFile sends the notification for a single processed file
+func (m *Manager) notifyFile(r batchResult) {
+	base := filepath.Base(r.file)
+
+	if r.err != nil {
+		m.send(notify.Notification{
+			Summary: fmt.Sprintf("Failed: %s", base),
+			Body:    r.err.Error(),
+			Urgency: notify.UrgencyCritical,
+			Actions: []notify.Action{
+				{Key: "log", Label: "View log", Run: func() { notify.Open(LogPath()) }},
+				{Key: "folder", Label: "Show in folder", Run: func() { m.showInFolder(r.file) }},
+			},
+		})
+		return
+	}
+
+	note := notify.Notification{
+		Summary: fmt.Sprintf("Processed: %s", base),
+		Body:    filepath.Dir(r.file),
+		Actions: []notify.Action{
+			{Key: "default", Label: "Open", Run: func() { openResult(r.file) }},
+			{Key: "folder", Label: "Show in folder", Run: func() { m.showInFolder(r.file) }},
+		},
+	}
+	if r.undoable() {
+		note.Actions = append(note.Actions, notify.Action{Key: "undo", Label: "Undo", Run: func() {
+			if _, err := m.UndoRun(r.run); err != nil {
+				log.Printf("Undo failed for %s: %v", r.file, err)
+				m.send(notify.Notification{Summary: fmt.Sprintf("Undo failed: %s", base), Body: err.Error()})
+				return
+			}
+			log.Printf("Undone: %s", r.file)
+		}})
+	}
+	m.send(note)
+}
+
+// openResult opens a processed file, or its folder if the action replaced it
+func openResult(filePath string) {
+	if _, err := os.Stat(filePath); err != nil {
+		notify.Open(filepath.Dir(filePath))
+		return
+	}
+	notify.Open(filePath)
+}
+
+func (m *Manager) showInFolder(filePath string) {
+	if m.notifier == nil {
+		notify.Open(filepath.Dir(filePath))
+		return
+	}
+	m.notifier.ShowInFolder(filePath)
+}
+
+// send shows a notification over D-Bus, falling back to notify-send (without
+// actions) when no notifier is available
+func (m *Manager) send(note notify.Notification) {
+	if m.notifier != nil {
+		_, err := m.notifier.Notify(note)
+		if err == nil {
+			return
+		}
+		log.Printf("Warning: notification failed: %v", err)
+	}
+	exec.Command("notify-send", "Gato: "+note.Summary, note.Body).Run()
+}
+
+// SetNotifier makes the manager send notifications over D-Bus
+func (m *Manager) SetNotifier(n *notify.Notifier) {
+	m.notifier = n
+}
+
+// StateDir is where the daemon keeps logs and other runtime state
+func StateDir() string {
+	stateDir := os.Getenv("XDG_STATE_HOME")
+	if stateDir == "" {
+		homeDir, _ := os.UserHomeDir()
+		stateDir = filepath.Join(homeDir, ".local", "state")
+	}
+	return filepath.Join(stateDir, "gato")
+}
+
+// LogPath is the daemon's log file, opened by "View log"
+func LogPath() string {
+	return filepath.Join(StateDir(), "daemon.log")
+}
+
+// notificationsDir holds the per-batch detail files opened from notifications
+func notificationsDir() string {
+	return filepath.Join(StateDir(), "notifications")
+}
+
+// writeBatchDetails writes one line per file and prunes detail files older than a day
+func writeBatchDetails(folder string, results []batchResult) (string, error) {
+	dir := notificationsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > 24*time.Hour {
+				os.Remove(filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n%s\n\n", folder, time.Now().Format(time.RFC1123))
+	for _, r := range results {
+		if r.err != nil {
+			fmt.Fprintf(&sb, "FAILED     %s: %v\n", filepath.Base(r.file), r.err)
+		} else {
+			fmt.Fprintf(&sb, "processed  %s\n", filepath.Base(r.file))
+		}
+	}
+
+	name := fmt.Sprintf("%s-%s.txt", filepath.Base(folder), time.Now().Format("20060102-150405.000"))
+	detailsPath := filepath.Join(dir, name)
+	return detailsPath, os.WriteFile(detailsPath, []byte(sb.String()), 0644)
+}
+
+// summaryText formats e.g. "Photos: processed 198 files, 2 failed"
+func summaryText(folder string, processed, failed int) string {
+	text := fmt.Sprintf("%s: processed %d %s", folder, processed, plural(processed, "file", "files"))
+	if failed > 0 {
+		text += fmt.Sprintf(", %d failed", failed)
+	}
+	return text
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,96 @@
+package folders
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veinticinco/gato-daemon/internal/history"
+)
+
+// TestBatchPerFile runs every file through two actions and checks that
+// each file is notified once, whatever its number of actions
+func TestBatchPerFile(t *testing.T) {
+	m := newTestManager(t)
+	sent := filepath.Join(t.TempDir(), "sent")
+	fakeBin(t, map[string]string{"notify-send": `echo "$1" >> ` + sent + "\n"})
+
+	folder := FolderAction{Path: "/home/ana/Photos", NotifyLevel: NotifyAlways}
+	tests := []struct {
+		name  string
+		files int
+		fail  int // files whose second action fails
+		want  string
+	}{
+		{"one file", 1, 0, "Gato: Processed: photo0.png"},
+		{"one file failing", 1, 1, "Gato: Failed: photo0.png"},
+		{"many files", 200, 0, "Gato: Photos: processed 200 files"},
+		{"many files failing", 3, 2, "Gato: Photos: processed 1 file, 2 failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(sent)
+			for i := range tt.files {
+				job := history.Job{
+					Run:     fmt.Sprintf("run%d", i),
+					File:    fmt.Sprintf("%s/photo%d.png", folder.Path, i),
+					Outputs: []string{fmt.Sprintf("%s/photo%d.webp", folder.Path, i)},
+				}
+				m.notifyResult(folder, job, nil)
+				var err error
+				if i < tt.fail {
+					err = errors.New("exit status 1")
+				}
+				m.notifyResult(folder, job, err)
+			}
+			m.flushNotifications()
+
+			data, _ := os.ReadFile(sent)
+			if got := strings.TrimSpace(string(data)); got != tt.want {
+				t.Errorf("sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchCount(t *testing.T) {
+	failed := errors.New("exit status 1")
+	tests := []struct {
+		results []batchResult
+		files   int
+		failed  int
+	}{
+		{nil, 0, 0},
+		{[]batchResult{{file: "a", run: "1"}, {file: "b", run: "2", err: failed}}, 2, 1},
+		// a file dropped again while its batch was open
+		{[]batchResult{{file: "a", run: "1", err: failed}, {file: "a", run: "2"}}, 1, 1},
+		{[]batchResult{{file: "a", run: "1"}, {file: "a", run: "2", err: failed}, {file: "b", run: "3"}}, 2, 1},
+	}
+	for _, tt := range tests {
+		b := &batch{results: tt.results}
+		if files, failed := b.count(); files != tt.files || failed != tt.failed {
+			t.Errorf("count(%v) = %d, %d; want %d, %d", tt.results, files, failed, tt.files, tt.failed)
+		}
+	}
+}
+
+func TestBatchResultUndoable(t *testing.T) {
+	tests := []struct {
+		r    batchResult
+		want bool
+	}{
+		{batchResult{added: true}, true},
+		{batchResult{replaced: true}, false},
+		{batchResult{replaced: true, added: true}, false},
+		{batchResult{replaced: true, kept: true}, true},
+		{batchResult{}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.r.undoable(); got != tt.want {
+			t.Errorf("%+v.undoable() = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+}
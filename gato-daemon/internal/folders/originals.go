@@ -0,0 +1,86 @@
+package folders
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/veinticinco/gato-daemon/internal/originals"
+)
+
+// Originals returns the store of kept originals for a folder with its
+// retention policy applied
+func (m *Manager) Originals(path string) (*originals.Store, error) {
+	path = expandHome(path)
+	keep, err := m.retention(path)
+	if err != nil {
+		return nil, err
+	}
+	return originals.Open(filepath.Join(path, ".originals"), keep), nil
+}
+
+// retention reads a folder's retention settings, falling back to the defaults
+func (m *Manager) retention(path string) (originals.Retention, error) {
+	keep := originals.DefaultRetention
+	s := m.settings(path)
+	if s == nil {
+		return keep, nil
+	}
+
+	if s.OriginalsMaxAge != "" {
+		age, err := originals.ParseAge(s.OriginalsMaxAge)
+		if err != nil {
+			return keep, fmt.Errorf("%s: %w", path, err)
+		}
+		keep.MaxAge = age
+	}
+	if s.OriginalsMaxSize != "" {
+		size, err := originals.ParseSize(s.OriginalsMaxSize)
+		if err != nil {
+			return keep, fmt.Errorf("%s: %w", path, err)
+		}
+		keep.MaxSize = size
+	}
+	return keep, nil
+}
+
+// SetRetention changes how long and how much a folder keeps in .originals.
+// Empty values are left unchanged.
+func (m *Manager) SetRetention(path, maxAge, maxSize string) error {
+	path = expandHome(path)
+	if len(m.GetFolderActions(path)) == 0 {
+		return fmt.Errorf("folder not found: %s", path)
+	}
+	if maxAge != "" {
+		if _, err := originals.ParseAge(maxAge); err != nil {
+			return err
+		}
+	}
+	if maxSize != "" {
+		if _, err := originals.ParseSize(maxSize); err != nil {
+			return err
+		}
+	}
+
+	s := m.ensureSettings(path)
+	if maxAge != "" {
+		s.OriginalsMaxAge = maxAge
+	}
+	if maxSize != "" {
+		s.OriginalsMaxSize = maxSize
+	}
+	return m.SaveConfig()
+}
+
+// keepOriginal stores a copy of filePath in its folder's .originals and
+// returns the path of the stored content
+func (m *Manager) keepOriginal(folder, filePath string) (string, error) {
+	store, err := m.Originals(folder)
+	if err != nil {
+		return "", err
+	}
+	v, err := store.Put(filePath)
+	if err != nil {
+		return "", err
+	}
+	return store.ObjectPath(v.Hash), nil
+}
@@ -0,0 +1,126 @@
+package folders
+
+import (
+	"fmt"
+	"time"
+)
+
+// FolderSettings holds per-folder state that isn't tied to a single action
+type FolderSettings struct {
+	Path        string    `toml:"path" json:"path"`
+	Enabled     *bool     `toml:"enabled,omitempty" json:"enabled"` // nil = enabled
+	PausedUntil time.Time `toml:"paused_until" json:"paused_until"` // resume automatically at this time (zero = never)
+
+	// Retention for .originals, e.g. "30d" and "2G"; empty = default, "0" = no limit
+	OriginalsMaxAge  string `toml:"originals_max_age,omitempty" json:"originals_max_age"`
+	OriginalsMaxSize string `toml:"originals_max_size,omitempty" json:"originals_max_size"`
+}
+
+// IsEnabled reports whether an action should run; actions are enabled unless
+// explicitly turned off
+func (f FolderAction) IsEnabled() bool {
+	return f.Enabled == nil || *f.Enabled
+}
+
+// settings returns the settings entry for a folder, or nil
+func (m *Manager) settings(path string) *FolderSettings {
+	for i := range m.config.Settings {
+		if m.config.Settings[i].Path == path {
+			return &m.config.Settings[i]
+		}
+	}
+	return nil
+}
+
+// FolderSettings returns the settings for a folder (zero value if none)
+func (m *Manager) FolderSettings(path string) FolderSettings {
+	if s := m.settings(expandHome(path)); s != nil {
+		return *s
+	}
+	return FolderSettings{Path: expandHome(path)}
+}
+
+// FolderEnabled reports whether a folder is active right now. A timed pause
+// that has expired counts as enabled.
+func (m *Manager) FolderEnabled(path string) bool {
+	s := m.settings(expandHome(path))
+	if s == nil || s.Enabled == nil || *s.Enabled {
+		return true
+	}
+	return !s.PausedUntil.IsZero() && !time.Now().Before(s.PausedUntil)
+}
+
+// PauseFolder stops processing a folder. A zero until pauses indefinitely.
+func (m *Manager) PauseFolder(path string, until time.Time) error {
+	path = expandHome(path)
+	if len(m.GetFolderActions(path)) == 0 {
+		return fmt.Errorf("folder not found: %s", path)
+	}
+
+	disabled := false
+	s := m.ensureSettings(path)
+	s.Enabled = &disabled
+	s.PausedUntil = until
+	return m.SaveConfig()
+}
+
+// ResumeFolder re-enables a paused folder
+func (m *Manager) ResumeFolder(path string) error {
+	path = expandHome(path)
+	if len(m.GetFolderActions(path)) == 0 {
+		return fmt.Errorf("folder not found: %s", path)
+	}
+	if s := m.settings(path); s != nil {
+		s.Enabled = nil
+		s.PausedUntil = time.Time{}
+		if s.OriginalsMaxAge == "" && s.OriginalsMaxSize == "" {
+			m.removeSettings(path)
+		}
+	}
+	return m.SaveConfig()
+}
+
+// ensureSettings returns the settings entry for a folder, adding one if needed
+func (m *Manager) ensureSettings(path string) *FolderSettings {
+	if s := m.settings(path); s != nil {
+		return s
+	}
+	m.config.Settings = append(m.config.Settings, FolderSettings{Path: path})
+	return &m.config.Settings[len(m.config.Settings)-1]
+}
+
+func (m *Manager) removeSettings(path string) {
+	var kept []FolderSettings
+	for _, s := range m.config.Settings {
+		if s.Path != path {
+			kept = append(kept, s)
+		}
+	}
+	m.config.Settings = kept
+}
+
+// SetActionEnabled turns a single action on or off
+func (m *Manager) SetActionEnabled(id string, enabled bool) (FolderAction, error) {
+	return m.UpdateAction(id, func(f *FolderAction) {
+		if enabled {
+			f.Enabled = nil
+		} else {
+			f.Enabled = &enabled
+		}
+	})
+}
+
+// nextResume returns the earliest pending timed resume, or zero
+func (m *Manager) nextResume() time.Time {
+	var next time.Time
+	now := time.Now()
+	for _, s := range m.config.Settings {
+		if !s.PausedUntil.After(now) {
+			continue
+		}
+		if next.IsZero() || s.PausedUntil.Before(next) {
+			next = s.PausedUntil
+		}
+	}
+	return next
+}
@@ -0,0 +1,127 @@
+package folders
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/veinticinco/gato-daemon/internal/fsutil"
+)
+
+// maxTestOutput caps how much command output a test keeps
+const maxTestOutput = 64 << 10
+
+// TestOutput is a file an action created
+type TestOutput struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// TestResult describes what one action did to the test copy
+type TestResult struct {
+	Action   string       `json:"action"`
+	Skipped  string       `json:"skipped,omitempty"` // why the action did not run
+	Before   int64        `json:"bytes_before"`
+	After    int64        `json:"bytes_after"` // -1 if the file is gone
+	Replaced bool         `json:"replaced"`    // the file was changed or removed
+	Outputs  []TestOutput `json:"outputs"`
+	Duration float64      `json:"duration"` // seconds
+	ExitCode int          `json:"exit_code"`
+	Output   string       `json:"output"` // stdout and stderr, truncated
+	Error    string       `json:"error,omitempty"`
+}
+
+// limitedBuffer keeps the first max bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.Buffer.Write(p[:max(room, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// TestActions runs actions in order on a copy of file in a new temporary
+// directory, which is returned so the results can be inspected. Nothing
+// is recorded in the history and no notifications are sent. Files the
+// actions trash go to a trash inside the directory.
+func (m *Manager) TestActions(ctx context.Context, file string, actions []FolderAction) (string, []TestResult, error) {
+	dir, err := os.MkdirTemp("", "gato-test-")
+	if err != nil {
+		return "", nil, err
+	}
+	work := filepath.Join(dir, "files")
+	if err := os.Mkdir(work, 0755); err != nil {
+		return dir, nil, err
+	}
+	ctx = withDataHome(ctx, filepath.Join(dir, "data"))
+
+	copyPath := filepath.Join(work, filepath.Base(file))
+	if err := fsutil.Copy(file, copyPath); err != nil {
+		return dir, nil, err
+	}
+
+	var results []TestResult
+	for _, a := range actions {
+		a.Path = work
+		result := TestResult{Action: m.describeAction(a), After: -1, Outputs: []TestOutput{}}
+		info, err := os.Stat(copyPath)
+		switch {
+		case err != nil:
+			result.Skipped = "an earlier action removed the file"
+		case !a.Matches(copyPath):
+			result.Skipped = "only for " + strings.Join(a.Extensions, ", ")
+		}
+		if result.Skipped != "" {
+			results = append(results, result)
+			continue
+		}
+		result.Before = info.Size()
+
+		if a.KeepOriginal {
+			m.keepOriginal(work, copyPath)
+		}
+		before := snapshotDir(work)
+		out := &limitedBuffer{max: maxTestOutput}
+		start := time.Now()
+		cmdErr := m.runAction(withOutput(ctx, out), copyPath, a)
+		result.Duration = time.Since(start).Seconds()
+
+		result.Output = out.String()
+		if out.truncated {
+			result.Output += "\n[output truncated]"
+		}
+		if cmdErr != nil {
+			result.Error = cmdErr.Error()
+			result.ExitCode = -1
+			var exitErr *exec.ExitError
+			if errors.As(cmdErr, &exitErr) {
+				result.ExitCode = exitErr.ExitCode()
+			}
+		}
+
+		outputs, replaced := before.diff(copyPath)
+		result.Replaced = replaced
+		for _, o := range outputs {
+			if info, err := os.Stat(o); err == nil {
+				result.Outputs = append(result.Outputs, TestOutput{Path: o, Size: info.Size()})
+			}
+		}
+		if info, err := os.Stat(copyPath); err == nil {
+			result.After = info.Size()
+		}
+		results = append(results, result)
+	}
+	return dir, results, nil
+}
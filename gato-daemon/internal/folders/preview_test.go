@@ -0,0 +1,94 @@
+package folders
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeBin puts scripts named after programs first on PATH
+func fakeBin(t *testing.T, scripts map[string]string) {
+	t.Helper()
+	bin := t.TempDir()
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestTestActions(t *testing.T) {
+	m := newTestManager(t)
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	fakeBin(t, map[string]string{"convert": `cp "$1" "$(eval echo \${$#})"` + "\n"})
+
+	file := filepath.Join(t.TempDir(), "shot.png")
+	if err := os.WriteFile(file, []byte("png data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, results, err := m.TestActions(context.Background(), file, []FolderAction{
+		{Command: `echo "data=$XDG_DATA_HOME"`},
+		{Action: "convert-webp"},
+		{Command: "cat {}", Extensions: []string{"png"}},
+		{Command: "exit 3"},
+	})
+	if dir != "" {
+		defer os.RemoveAll(dir)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := os.Getenv("XDG_DATA_HOME"); got != dataHome {
+		t.Errorf("XDG_DATA_HOME changed to %s", got)
+	}
+	if want := "data=" + filepath.Join(dir, "data"); strings.TrimSpace(results[0].Output) != want {
+		t.Errorf("command saw %q, want %q", results[0].Output, want)
+	}
+
+	webp := results[1]
+	if webp.Error != "" || webp.After != -1 || len(webp.Outputs) != 1 || filepath.Base(webp.Outputs[0].Path) != "shot.webp" {
+		t.Errorf("convert-webp result = %+v", webp)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "data", "Trash", "files", "shot.png")); err != nil {
+		t.Errorf("original not in the test trash: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dataHome, "Trash", "files")); len(entries) > 0 {
+		t.Errorf("real trash has %d files", len(entries))
+	}
+
+	if results[2].Skipped == "" {
+		t.Errorf("action on a removed file ran: %+v", results[2])
+	}
+	if results[3].Skipped == "" {
+		t.Errorf("action after the file was removed ran: %+v", results[3])
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("sample file touched: %v", err)
+	}
+}
+
+func TestTestActionsExitCode(t *testing.T) {
+	m := newTestManager(t)
+	file := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(file, []byte("hello"), 0644)
+
+	dir, results, err := m.TestActions(context.Background(), file, []FolderAction{
+		{Command: "echo oops >&2; exit 3 # {}"},
+		{Command: "echo more >> {}"},
+	})
+	defer os.RemoveAll(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := results[0]; r.ExitCode != 3 || strings.TrimSpace(r.Output) != "oops" || r.Error == "" {
+		t.Errorf("failing command result = %+v", r)
+	}
+	if r := results[1]; r.Before != 5 || r.After != 10 || !r.Replaced {
+		t.Errorf("appending command result = %+v", r)
+	}
+}
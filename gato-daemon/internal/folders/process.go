@@ -0,0 +1,48 @@
+package folders
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/veinticinco/gato-daemon/internal/history"
+)
+
+// ProcessFiles runs one action on files anywhere, without a configured
+// folder, and waits for them. It goes through the same queue, workers and
+// history as the daemon, so originals are kept in .originals next to each
+// file and the runs can be undone. Files that were skipped or cancelled
+// before they started have no history job.
+func (m *Manager) ProcessFiles(ctx context.Context, files []string, action FolderAction) ([]history.Job, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m.startWorkers(ctx)
+
+	runs := make(map[string]bool)
+	var jobs []*Job
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		a := action
+		a.Path = filepath.Dir(file)
+		job := m.enqueue(ctx, file, a.Path, []FolderAction{a})
+		runs[job.ID] = true
+		jobs = append(jobs, job)
+	}
+	for _, job := range jobs {
+		<-job.done
+	}
+	m.flushNotifications()
+
+	all, err := m.history.All()
+	if err != nil {
+		return nil, err
+	}
+	var done []history.Job
+	for _, j := range all {
+		if runs[j.Run] {
+			done = append(done, j)
+		}
+	}
+	return done, nil
+}
@@ -0,0 +1,223 @@
+package folders
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/veinticinco/gato-daemon/internal/notify"
+)
+
+// progressInterval limits how often progress is published as events and
+// notification updates
+const progressInterval = time.Second
+
+// progressNotifyAfter is how long an action must be expected to take before
+// a progress notification is shown
+const progressNotifyAfter = 10 * time.Second
+
+// progressFunc receives how far the current action is, from 0 to 1, and
+// how long it is expected to take still
+type progressFunc func(fraction float64, remaining time.Duration)
+
+type progressKey struct{}
+
+// withProgress makes commands run under ctx report progress to fn
+func withProgress(ctx context.Context, fn progressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressFrom returns the reporter set with withProgress, or nil
+func progressFrom(ctx context.Context) progressFunc {
+	fn, _ := ctx.Value(progressKey{}).(progressFunc)
+	return fn
+}
+
+// mediaDuration asks ffprobe how long a file plays
+func mediaDuration(ctx context.Context, file string) (time.Duration, error) {
+	out, err := exec.CommandContext(ctx, "ffprobe", "-v", "error",
+		"-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", file).Output()
+	if err != nil {
+		return 0, err
+	}
+	secs, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || secs <= 0 {
+		return 0, fmt.Errorf("no duration for %s", file)
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+// ffmpegProgressArgs make ffmpeg write machine-readable progress to fd 3
+var ffmpegProgressArgs = []string{"-progress", "pipe:3", "-nostats"}
+
+// ffmpegCall finds the first ffmpeg invocation in a shell command
+var ffmpegCall = regexp.MustCompile(`(^|[\s;&|(])((?:\S*/)?ffmpeg)\s`)
+
+// withFFmpegProgress adds the progress arguments to the first ffmpeg in a
+// custom command, reporting whether there was one
+func withFFmpegProgress(command string) (string, bool) {
+	loc := ffmpegCall.FindStringSubmatchIndex(command)
+	if loc == nil {
+		return command, false
+	}
+	end := loc[5] // end of the ffmpeg word
+	return command[:end] + " " + strings.Join(ffmpegProgressArgs, " ") + command[end:], true
+}
+
+// runWithProgress runs cmd, which writes ffmpeg progress to fd 3, and
+// reports it against the duration of input. Without a reporter or a
+// known duration the progress goes to /dev/null.
+func runWithProgress(ctx context.Context, cmd *exec.Cmd, input string) error {
+	report := progressFrom(ctx)
+	var duration time.Duration
+	if report != nil {
+		duration, _ = mediaDuration(ctx, input)
+	}
+	if duration == 0 {
+		null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer null.Close()
+		cmd.ExtraFiles = []*os.File{null}
+		return cmd.Run()
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd.ExtraFiles = []*os.File{w}
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return err
+	}
+	w.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		parseFFmpegProgress(r, duration, time.Now(), report)
+	}()
+	err = cmd.Wait()
+	// Something the command started in the background may still hold the pipe
+	r.Close()
+	<-done
+	return err
+}
+
+// parseFFmpegProgress reads key=value blocks from ffmpeg -progress, each
+// ending with a progress= line
+func parseFFmpegProgress(r io.Reader, duration time.Duration, started time.Time, report progressFunc) {
+	scanner := bufio.NewScanner(r)
+	var position time.Duration
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "out_time_us", "out_time_ms": // both are microseconds
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil && us >= 0 {
+				position = time.Duration(us) * time.Microsecond
+			}
+		case "progress":
+			fraction := min(float64(position)/float64(duration), 1)
+			if value == "end" {
+				fraction = 1
+			}
+			var remaining time.Duration
+			if fraction > 0.01 {
+				elapsed := time.Since(started)
+				remaining = time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+			}
+			report(fraction, remaining)
+		}
+	}
+}
+
+// jobProgress tracks what has been published about a running job. It is
+// kept by the worker running the job, outside of Job, so snapshots taken
+// under the queue lock never race with it.
+type jobProgress struct {
+	step      int
+	published time.Time
+	logged    int    // last logged tenth of the step
+	noteID    uint32 // progress notification, 0 = none shown
+}
+
+// actionProgress publishes the progress of a job's step-th action through
+// the job, events, the log and, for actions that notify, a notification
+func (m *Manager) actionProgress(job *Job, p *jobProgress, step int, action FolderAction, fraction float64, remaining time.Duration) {
+	if p.step != step {
+		p.step, p.logged = step, 0
+	}
+	if fraction < 1 && time.Since(p.published) < progressInterval {
+		return
+	}
+	p.published = time.Now()
+
+	eta := time.Now().Add(remaining).Truncate(time.Second)
+	m.queue.mu.Lock()
+	job.Progress = (float64(step) + fraction) / float64(len(job.actions))
+	job.ETA = nil
+	if remaining > 0 {
+		job.ETA = &eta
+	}
+	started := *job.Started
+	m.queue.mu.Unlock()
+	m.jobEvent(EventJobProgress, job, Event{})
+	m.statusChanged()
+
+	percent := int(fraction * 100)
+	if tenth := percent / 10; tenth > p.logged && fraction < 1 {
+		p.logged = tenth
+		log.Printf("Progress: %s %d%%%s", job.File, percent, etaText(remaining))
+	}
+
+	if m.notifier == nil || action.NotifyMode() != NotifyAlways || fraction >= 1 {
+		return
+	}
+	if p.noteID == 0 && time.Since(started)+remaining < progressNotifyAfter {
+		return
+	}
+	id, err := m.notifier.Notify(notify.Notification{
+		Summary:    fmt.Sprintf("Processing: %s", filepath.Base(job.File)),
+		Body:       fmt.Sprintf("%d%%%s", percent, etaText(remaining)),
+		Urgency:    notify.UrgencyLow,
+		ReplacesID: p.noteID,
+		Progress:   percent,
+		Actions: []notify.Action{
+			{Key: "cancel", Label: "Cancel", Run: func() { m.CancelJob(job.ID) }},
+		},
+	})
+	if err == nil {
+		p.noteID = id
+	}
+}
+
+// endProgress removes a job's progress notification once it is done
+func (m *Manager) endProgress(p *jobProgress) {
+	if p.noteID != 0 && m.notifier != nil {
+		m.notifier.Dismiss(p.noteID)
+	}
+	*p = jobProgress{}
+}
+
+// etaText formats e.g. ", 1m20s left", or nothing if unknown
+func etaText(remaining time.Duration) string {
+	if remaining <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %s left", remaining.Round(time.Second))
+}
@@ -0,0 +1,107 @@
+package folders
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestManager returns a manager whose config and state live in a
+// temporary home
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", home+"/.local/state")
+	t.Setenv("XDG_DATA_HOME", home+"/.local/share")
+	return New()
+}
+
+func TestParseFFmpegProgress(t *testing.T) {
+	stream := "frame=1\nout_time_us=2500000\nprogress=continue\n" +
+		"out_time_ms=5000000\nprogress=continue\n" +
+		"bogus\nprogress=end\n"
+	var got []float64
+	parseFFmpegProgress(strings.NewReader(stream), 10*time.Second, time.Now(), func(fraction float64, _ time.Duration) {
+		got = append(got, fraction)
+	})
+	want := []float64{0.25, 0.5, 1}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("fractions = %v, want %v", got, want)
+	}
+}
+
+func TestWithFFmpegProgress(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+		ok      bool
+	}{
+		{"ffmpeg -i {} out.mp4", "ffmpeg -progress pipe:3 -nostats -i {} out.mp4", true},
+		{"nice /usr/bin/ffmpeg -i {} x", "nice /usr/bin/ffmpeg -progress pipe:3 -nostats -i {} x", true},
+		{"convert {} {dir}/{name}.webp", "convert {} {dir}/{name}.webp", false},
+		{"echo ffmpeg-static", "echo ffmpeg-static", false},
+	}
+	for _, tt := range tests {
+		got, ok := withFFmpegProgress(tt.command)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("withFFmpegProgress(%q) = %q, %v; want %q, %v", tt.command, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestProgressWhileListingJobs runs a progress stream through a job while
+// its snapshots are taken, as gato status and gato events do. Run with -race.
+func TestProgressWhileListingJobs(t *testing.T) {
+	m := newTestManager(t)
+	started := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	job := &Job{
+		ID: "job1", File: "/tmp/video.mov", Folder: "/tmp", State: JobRunning,
+		Queued: started, Started: &started,
+		actions: []FolderAction{{Action: "convert-mp4"}},
+		ctx:     ctx, cancel: cancel,
+	}
+	m.queue.jobs[job.ID] = job
+	events, stop := m.Subscribe()
+	defer stop()
+
+	r, w := io.Pipe()
+	go func() {
+		for i := 1; i <= 200; i++ {
+			fmt.Fprintf(w, "out_time_us=%d\nprogress=end\n", i*10000)
+		}
+		w.Close()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var progress jobProgress
+		parseFFmpegProgress(r, 2*time.Second, started, func(fraction float64, remaining time.Duration) {
+			m.actionProgress(job, &progress, 0, job.actions[0], fraction, remaining)
+		})
+		m.endProgress(&progress)
+	}()
+
+	for {
+		select {
+		case <-done:
+			jobs := m.Jobs()
+			if len(jobs) != 1 || jobs[0].Progress != 1 {
+				t.Fatalf("jobs = %+v, want one job at progress 1", jobs)
+			}
+			return
+		case e := <-events:
+			if e.Job == nil || e.Job.ID != job.ID {
+				t.Errorf("unexpected event %+v", e)
+			}
+		default:
+			m.Jobs()
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package folders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/veinticinco/gato-daemon/internal/history"
+)
+
+// Job states
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+)
+
+// settleDelay gives new files time to be fully written before processing
+const settleDelay = 500 * time.Millisecond
+
+// Job is a file waiting for, or going through, its folder's actions
+type Job struct {
+	ID       string     `json:"id"` // also the history run ID
+	File     string     `json:"file"`
+	Folder   string     `json:"folder"`
+	State    string     `json:"state"`  // queued, running
+	Action   string     `json:"action"` // action being run, empty while queued
+	Queued   time.Time  `json:"queued"`
+	Started  *time.Time `json:"started"`  // nil while queued
+	Progress float64    `json:"progress"` // 0 to 1
+	ETA      *time.Time `json:"eta"`      // when the current action should finish, if known
+
+	actions []FolderAction
+	run     *run
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{} // closed when the job has finished or was cancelled
+}
+
+// ErrCancelled is recorded for jobs stopped with CancelJob
+var ErrCancelled = errors.New("cancelled")
+
+// queue holds jobs until a worker picks them up
+type queue struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	pending []*Job
+	wake    chan struct{}
+}
+
+// workers is how many files are processed at once. Conversions are
+// multithreaded themselves, so this stays below the CPU count.
+func workers() int {
+	return max(1, runtime.NumCPU()/2)
+}
+
+// enqueue adds a file to the queue. Cancelling ctx stops the job.
+func (m *Manager) enqueue(ctx context.Context, filePath, folder string, actions []FolderAction) *Job {
+	id := history.NewID()
+	ctx, cancel := context.WithCancel(ctx)
+	job := &Job{
+		ID:      id,
+		File:    filePath,
+		Folder:  folder,
+		State:   JobQueued,
+		Queued:  time.Now(),
+		actions: actions,
+		run:     &run{id: id},
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	q := &m.queue
+	q.mu.Lock()
+	q.jobs[id] = job
+	q.pending = append(q.pending, job)
+	q.mu.Unlock()
+	q.signal()
+
+	m.jobEvent(EventJobQueued, job, Event{})
+	m.statusChanged()
+	return job
+}
+
+// signal wakes one idle worker
+func (q *queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next blocks until a job is available or ctx is done
+func (q *queue) next(ctx context.Context) *Job {
+	for {
+		q.mu.Lock()
+		if len(q.pending) > 0 {
+			job := q.pending[0]
+			q.pending = q.pending[1:]
+			more := len(q.pending) > 0
+			q.mu.Unlock()
+			if more {
+				q.signal()
+			}
+			return job
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-q.wake:
+		}
+	}
+}
+
+// startWorkers runs jobs until ctx is done
+func (m *Manager) startWorkers(ctx context.Context) {
+	for i := 0; i < workers(); i++ {
+		go func() {
+			for {
+				job := m.queue.next(ctx)
+				if job == nil {
+					return
+				}
+				m.runJob(job)
+			}
+		}()
+	}
+}
+
+// runJob sends a file through each of its folder's actions in order
+func (m *Manager) runJob(job *Job) {
+	if wait := time.Until(job.Queued.Add(settleDelay)); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	now := time.Now()
+	m.queue.mu.Lock()
+	if _, ok := m.queue.jobs[job.ID]; !ok {
+		// Cancelled while waiting
+		m.queue.mu.Unlock()
+		return
+	}
+	job.State = JobRunning
+	job.Started = &now
+	m.queue.mu.Unlock()
+
+	// Only this worker touches progress; the job's fields are published
+	// under the queue lock
+	var progress jobProgress
+	var failed error
+	for i, action := range job.actions {
+		if job.ctx.Err() != nil {
+			break
+		}
+		m.queue.mu.Lock()
+		job.Action = m.describeAction(action)
+		m.queue.mu.Unlock()
+		m.jobEvent(EventJobStarted, job, Event{Action: job.Action})
+		m.statusChanged()
+
+		ctx := withProgress(job.ctx, func(fraction float64, remaining time.Duration) {
+			m.actionProgress(job, &progress, i, action, fraction, remaining)
+		})
+		if err := m.processFile(ctx, job.File, action, job.run); err != nil && failed == nil {
+			failed = err
+		}
+		if i < len(job.actions)-1 {
+			m.setProgress(job, float64(i+1)/float64(len(job.actions)))
+		}
+	}
+
+	m.queue.mu.Lock()
+	delete(m.queue.jobs, job.ID)
+	if failed == nil && job.ctx.Err() == nil {
+		job.Progress = 1
+	}
+	job.ETA = nil
+	m.queue.mu.Unlock()
+	m.endProgress(&progress)
+	m.jobEvent(EventJobFinished, job, finishedEvent(job, failed))
+	job.cancel()
+	close(job.done)
+	m.statusChanged()
+}
+
+// finishedEvent describes how a job ended
+func finishedEvent(job *Job, err error) Event {
+	switch {
+	case job.ctx.Err() != nil:
+		return Event{Status: "cancelled"}
+	case err != nil:
+		return Event{Status: "failed", Error: err.Error()}
+	default:
+		return Event{Status: "ok"}
+	}
+}
+
+// minJobPrefix is the shortest job ID prefix CancelJob accepts, so a
+// typo cannot match whichever job happens to be alone in the queue
+const minJobPrefix = 4
+
+// CancelJob removes a queued job or stops a running one. id may be a
+// unique prefix of at least minJobPrefix characters.
+func (m *Manager) CancelJob(id string) (Job, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if len(id) < minJobPrefix {
+		return Job{}, fmt.Errorf("job ID %q is too short, give at least %d characters", id, minJobPrefix)
+	}
+
+	q := &m.queue
+	q.mu.Lock()
+	var job *Job
+	for jobID, j := range q.jobs {
+		if strings.HasPrefix(jobID, id) {
+			if job != nil {
+				q.mu.Unlock()
+				return Job{}, fmt.Errorf("job ID %s is ambiguous", id)
+			}
+			job = j
+		}
+	}
+	if job == nil {
+		q.mu.Unlock()
+		return Job{}, fmt.Errorf("no such job: %s", id)
+	}
+	snapshot := m.dequeue(job)
+	q.mu.Unlock()
+
+	m.stopJob(job, snapshot)
+	return snapshot, nil
+}
+
+// CancelFolder cancels every queued and running job in a folder
+func (m *Manager) CancelFolder(folder string) ([]Job, error) {
+	folder = expandHome(folder)
+	if abs, err := filepath.Abs(folder); err == nil {
+		folder = abs
+	}
+
+	q := &m.queue
+	q.mu.Lock()
+	var jobs []*Job
+	var snapshots []Job
+	for _, j := range q.jobs {
+		if j.Folder == folder {
+			jobs = append(jobs, j)
+			snapshots = append(snapshots, m.dequeue(j))
+		}
+	}
+	q.mu.Unlock()
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no jobs in %s", folder)
+	}
+
+	for i, j := range jobs {
+		m.stopJob(j, snapshots[i])
+	}
+	sort.Slice(snapshots, func(a, b int) bool { return snapshots[a].Queued.Before(snapshots[b].Queued) })
+	return snapshots, nil
+}
+
+// dequeue takes a queued job off the queue and returns a snapshot of it.
+// Called with the queue locked.
+func (m *Manager) dequeue(job *Job) Job {
+	q := &m.queue
+	if job.State == JobQueued {
+		for i, j := range q.pending {
+			if j == job {
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				break
+			}
+		}
+		delete(q.jobs, job.ID)
+	}
+	return *job
+}
+
+// stopJob finishes a job taken off the queue, or kills the process group
+// of a running one; runJob then cleans up after it
+func (m *Manager) stopJob(job *Job, snapshot Job) {
+	job.cancel()
+	if snapshot.State == JobQueued {
+		m.jobEvent(EventJobFinished, job, Event{Status: "cancelled"})
+		close(job.done)
+	}
+	log.Printf("Cancelled: %s", job.File)
+	m.statusChanged()
+}
+
+// Jobs returns a snapshot of queued and running jobs, oldest first
+func (m *Manager) Jobs() []Job {
+	m.queue.mu.Lock()
+	defer m.queue.mu.Unlock()
+
+	jobs := make([]Job, 0, len(m.queue.jobs))
+	for _, j := range m.queue.jobs {
+		jobs = append(jobs, *j)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].Queued.Before(jobs[b].Queued) })
+	return jobs
+}
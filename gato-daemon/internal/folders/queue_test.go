@@ -0,0 +1,53 @@
+package folders
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCancelJob(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    string
+		errText string
+	}{
+		{"", "", "too short"},
+		{"  ", "", "too short"},
+		{"a", "", "too short"},
+		{"abc", "", "too short"},
+		{"abcd", "", "ambiguous"},
+		{"ABCD12", "abcd1234", ""},
+		{" abcd5678 ", "abcd5678", ""},
+		{"ffff", "", "no such job"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			m := newTestManager(t)
+			for _, id := range []string{"abcd1234", "abcd5678"} {
+				ctx, cancel := context.WithCancel(context.Background())
+				job := &Job{ID: id, File: "/tmp/" + id, State: JobQueued, Queued: time.Now(), ctx: ctx, cancel: cancel, done: make(chan struct{})}
+				m.queue.jobs[id] = job
+				m.queue.pending = append(m.queue.pending, job)
+			}
+
+			job, err := m.CancelJob(tt.id)
+			if tt.errText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Fatalf("CancelJob(%q) = %v, want error containing %q", tt.id, err, tt.errText)
+				}
+				if len(m.Jobs()) != 2 {
+					t.Errorf("a job was cancelled after an error")
+				}
+				return
+			}
+			if err != nil || job.ID != tt.want {
+				t.Fatalf("CancelJob(%q) = %s, %v; want %s", tt.id, job.ID, err, tt.want)
+			}
+			if jobs := m.Jobs(); len(jobs) != 1 || jobs[0].ID == tt.want {
+				t.Errorf("jobs left = %+v", jobs)
+			}
+		})
+	}
+}
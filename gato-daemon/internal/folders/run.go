@@ -0,0 +1,163 @@
+package folders
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/veinticinco/gato-daemon/internal/history"
+)
+
+// RunOptions selects the existing files RunFolder queues
+type RunOptions struct {
+	Match  string    `json:"match"`   // glob on the file name, empty = all
+	Since  time.Time `json:"since"`   // only files modified after this, zero = all
+	DryRun bool      `json:"dry_run"` // report what would be queued
+}
+
+// RunResult is what RunFolder queued, or would queue on a dry run
+type RunResult struct {
+	Jobs    []Job `json:"jobs"`    // dry runs have no job IDs
+	Skipped int   `json:"skipped"` // files already processed or created by processing
+}
+
+// RunFolder queues the files already in a folder, as if each had just been
+// added. The history ledger is checked so no action runs twice on a file
+// and files an action produced are not processed themselves.
+func (m *Manager) RunFolder(folder string, opts RunOptions) (RunResult, error) {
+	var result RunResult
+	err := m.do(func(ctx context.Context) error {
+		var err error
+		result, err = m.runFolder(ctx, folder, opts)
+		return err
+	})
+	return result, err
+}
+
+func (m *Manager) runFolder(ctx context.Context, folder string, opts RunOptions) (RunResult, error) {
+	result := RunResult{Jobs: []Job{}}
+	folder = expandHome(folder)
+	if abs, err := filepath.Abs(folder); err == nil {
+		folder = abs
+	}
+	if opts.Match != "" {
+		if _, err := filepath.Match(opts.Match, ""); err != nil {
+			return result, fmt.Errorf("invalid pattern %q: %v", opts.Match, err)
+		}
+	}
+
+	actions := m.activeActions(folder)
+	if len(m.GetFolderActions(folder)) == 0 {
+		return result, fmt.Errorf("folder not found: %s", folder)
+	}
+	if !m.FolderEnabled(folder) {
+		return result, fmt.Errorf("%s is paused", folder)
+	}
+	if len(actions) == 0 {
+		return result, fmt.Errorf("no enabled actions for %s", folder)
+	}
+
+	done, outputs, err := m.ledger(folder)
+	if err != nil {
+		return result, err
+	}
+
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return result, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if opts.Match != "" {
+			if ok, _ := filepath.Match(opts.Match, name); !ok {
+				continue
+			}
+		}
+		info, err := e.Info()
+		if err != nil || (!opts.Since.IsZero() && info.ModTime().Before(opts.Since)) {
+			continue
+		}
+
+		file := filepath.Join(folder, name)
+		var pending []FolderAction
+		matched := false
+		for _, a := range actions {
+			if !a.Matches(file) {
+				continue
+			}
+			matched = true
+			if !done[file][a.ID] {
+				pending = append(pending, a)
+			}
+		}
+		if !matched {
+			continue
+		}
+		if len(pending) == 0 || outputs[file] {
+			result.Skipped++
+			continue
+		}
+
+		if opts.DryRun {
+			result.Jobs = append(result.Jobs, Job{File: file, Folder: folder, State: JobQueued, Queued: time.Now()})
+			continue
+		}
+		result.Jobs = append(result.Jobs, *m.enqueue(ctx, file, folder, pending))
+	}
+	return result, nil
+}
+
+// ledger reads which actions already ran successfully on each file in a
+// folder, and which files were created by them
+func (m *Manager) ledger(folder string) (done map[string]map[string]bool, outputs map[string]bool, err error) {
+	jobs, err := m.history.All()
+	if err != nil {
+		return nil, nil, err
+	}
+	done = make(map[string]map[string]bool)
+	outputs = make(map[string]bool)
+	for _, j := range jobs {
+		if j.Folder != folder || j.Status() != history.StatusOK {
+			continue
+		}
+		if done[j.File] == nil {
+			done[j.File] = make(map[string]bool)
+		}
+		done[j.File][j.ActionID] = true
+		for _, out := range j.Outputs {
+			outputs[out] = true
+		}
+	}
+	return done, outputs, nil
+}
+
+// activeActions returns a folder's enabled actions that have something to run
+func (m *Manager) activeActions(folder string) []FolderAction {
+	var actions []FolderAction
+	for _, a := range m.GetFolderActions(folder) {
+		if a.IsEnabled() && (a.Action != "" || a.Command != "") {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
+
+// Matches reports whether the action applies to a file by its extension
+func (f FolderAction) Matches(filePath string) bool {
+	if len(f.Extensions) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	for _, e := range f.Extensions {
+		if ext == "."+strings.ToLower(strings.TrimPrefix(e, ".")) {
+			return true
+		}
+	}
+	return false
+}
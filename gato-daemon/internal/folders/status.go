@@ -0,0 +1,197 @@
+package folders
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/veinticinco/gato-daemon/internal/fsutil"
+)
+
+// Watch states reported by the daemon
+const (
+	WatchOK     = "watching"
+	WatchPaused = "paused"
+	WatchIdle   = "idle" // no enabled actions
+	WatchError  = "error"
+)
+
+// WatchStatus is the daemon's view of one folder
+type WatchStatus struct {
+	Path        string     `json:"path"`
+	State       string     `json:"state"` // watching, paused, idle, error
+	Actions     int        `json:"actions"`
+	PausedUntil *time.Time `json:"paused_until"`
+	LastError   string     `json:"last_error"`
+	ErrorTime   *time.Time `json:"error_time"`
+}
+
+// DaemonStatus is what gato status shows
+type DaemonStatus struct {
+	Version string        `json:"version"`
+	PID     int           `json:"pid"`
+	Started time.Time     `json:"started"`
+	Folders []WatchStatus `json:"folders"`
+	Jobs    []Job         `json:"jobs"`
+	Issues  []Issue       `json:"config_issues"` // found on the last reload
+}
+
+// daemonInfo is set when the manager runs inside the daemon
+type daemonInfo struct {
+	version string
+	started time.Time
+
+	mu      sync.Mutex
+	watches map[string]*WatchStatus
+	issues  []Issue
+	pending *time.Timer // coalesces status file writes
+	stopped bool
+}
+
+// EnableStatus makes the manager publish its state to StatusPath for gato status
+func (m *Manager) EnableStatus(version string) {
+	m.daemon = &daemonInfo{
+		version: version,
+		started: time.Now(),
+		watches: make(map[string]*WatchStatus),
+	}
+}
+
+// StatusPath is where the running daemon publishes its status
+func StatusPath() string {
+	return filepath.Join(StateDir(), "status.json")
+}
+
+// Status returns the daemon's current state
+func (m *Manager) Status() DaemonStatus {
+	s := DaemonStatus{PID: os.Getpid(), Folders: []WatchStatus{}, Jobs: m.Jobs(), Issues: []Issue{}}
+	if m.daemon == nil {
+		return s
+	}
+	s.Version = m.daemon.version
+	s.Started = m.daemon.started
+
+	m.daemon.mu.Lock()
+	defer m.daemon.mu.Unlock()
+	for _, w := range m.daemon.watches {
+		s.Folders = append(s.Folders, *w)
+	}
+	s.Issues = append(s.Issues, m.daemon.issues...)
+	sort.Slice(s.Folders, func(a, b int) bool { return s.Folders[a].Path < s.Folders[b].Path })
+	return s
+}
+
+// setWatch records the watch state of a folder
+func (m *Manager) setWatch(w WatchStatus) {
+	if m.daemon == nil {
+		return
+	}
+	m.daemon.mu.Lock()
+	m.daemon.watches[w.Path] = &w
+	m.daemon.mu.Unlock()
+	m.statusChanged()
+}
+
+// watchError records an error reported while watching a folder
+func (m *Manager) watchError(path string, err error) {
+	m.emit(Event{Kind: EventWatchError, Path: path, Error: err.Error()})
+	if m.daemon == nil {
+		return
+	}
+	now := time.Now()
+	m.daemon.mu.Lock()
+	if w, ok := m.daemon.watches[path]; ok {
+		w.LastError = err.Error()
+		w.ErrorTime = &now
+	}
+	m.daemon.mu.Unlock()
+	m.statusChanged()
+}
+
+// setIssues records the problems found in the config
+func (m *Manager) setIssues(issues []Issue) {
+	if m.daemon == nil {
+		return
+	}
+	m.daemon.mu.Lock()
+	m.daemon.issues = issues
+	m.daemon.mu.Unlock()
+	m.statusChanged()
+}
+
+// resetWatches forgets folders before watchers are recreated
+func (m *Manager) resetWatches() {
+	if m.daemon == nil {
+		return
+	}
+	m.daemon.mu.Lock()
+	m.daemon.watches = make(map[string]*WatchStatus)
+	m.daemon.mu.Unlock()
+}
+
+// statusChanged schedules a write of the status file. Bursts of changes
+// produce a single write.
+func (m *Manager) statusChanged() {
+	if m.daemon == nil {
+		return
+	}
+	m.daemon.mu.Lock()
+	defer m.daemon.mu.Unlock()
+	if m.daemon.pending == nil && !m.daemon.stopped {
+		m.daemon.pending = time.AfterFunc(200*time.Millisecond, func() {
+			m.daemon.mu.Lock()
+			m.daemon.pending = nil
+			m.daemon.mu.Unlock()
+			m.writeStatus()
+		})
+	}
+}
+
+// writeStatus replaces the status file atomically
+func (m *Manager) writeStatus() {
+	data, err := json.MarshalIndent(m.Status(), "", "  ")
+	if err != nil {
+		return
+	}
+	path := StatusPath()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	fsutil.WriteFile(path, data, 0644)
+}
+
+// removeStatus deletes the status file when the daemon stops
+func (m *Manager) removeStatus() {
+	if m.daemon == nil {
+		return
+	}
+	m.daemon.mu.Lock()
+	m.daemon.stopped = true
+	if m.daemon.pending != nil {
+		m.daemon.pending.Stop()
+	}
+	m.daemon.mu.Unlock()
+	os.Remove(StatusPath())
+}
+
+// ReadStatus returns the status published by a running daemon. ok is false
+// if no daemon is running.
+func ReadStatus() (status DaemonStatus, ok bool, err error) {
+	data, err := os.ReadFile(StatusPath())
+	if os.IsNotExist(err) {
+		return status, false, nil
+	}
+	if err != nil {
+		return status, false, err
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return status, false, err
+	}
+	// A daemon that was killed leaves its status file behind
+	if status.PID <= 0 || syscall.Kill(status.PID, 0) == syscall.ESRCH {
+		return status, false, nil
+	}
+	return status, true, nil
+}
@@ -0,0 +1,257 @@
+package folders
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/veinticinco/gato-daemon/internal/fsutil"
+	"github.com/veinticinco/gato-daemon/internal/history"
+	"github.com/veinticinco/gato-daemon/internal/trash"
+)
+
+// suppressFor is how long an undone file is ignored by the watcher
+const suppressFor = 30 * time.Second
+
+// dirSnapshot records the files in a directory before an action runs so the
+// files it creates can be found afterwards
+type dirSnapshot struct {
+	dir   string
+	files map[string]os.FileInfo
+}
+
+func snapshotDir(dir string) dirSnapshot {
+	s := dirSnapshot{dir: dir, files: make(map[string]os.FileInfo)}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil {
+			s.files[e.Name()] = info
+		}
+	}
+	return s
+}
+
+// diff returns the files created since the snapshot and whether filePath
+// itself was modified or removed
+func (s dirSnapshot) diff(filePath string) (outputs []string, replaced bool) {
+	after := snapshotDir(s.dir)
+	for name, info := range after.files {
+		if _, existed := s.files[name]; !existed && !info.IsDir() && !strings.HasPrefix(name, ".") {
+			outputs = append(outputs, filepath.Join(s.dir, name))
+		}
+	}
+	sort.Strings(outputs)
+
+	base := filepath.Base(filePath)
+	old, now := s.files[base], after.files[base]
+	if old == nil {
+		return outputs, false
+	}
+	replaced = now == nil || now.Size() != old.Size() || !now.ModTime().Equal(old.ModTime())
+	return outputs, replaced
+}
+
+// UndoFile reverts the most recent processing of a file
+func (m *Manager) UndoFile(path string) ([]history.Job, error) {
+	path = expandHome(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	runs, err := m.pendingRuns()
+	if err != nil {
+		return nil, err
+	}
+	for _, jobs := range runs {
+		if jobs[0].File == path {
+			return jobs, m.undoRun(jobs)
+		}
+	}
+	return nil, fmt.Errorf("nothing to undo for %s", path)
+}
+
+// UndoLast reverts the n most recently processed files
+func (m *Manager) UndoLast(n int) ([]history.Job, error) {
+	runs, err := m.pendingRuns()
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+
+	var undone []history.Job
+	var errs []string
+	for i := 0; i < n && i < len(runs); i++ {
+		if err := m.undoRun(runs[i]); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		undone = append(undone, runs[i]...)
+	}
+	if len(errs) > 0 {
+		return undone, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return undone, nil
+}
+
+// UndoRun reverts one run, e.g. from a notification's Undo button
+func (m *Manager) UndoRun(id string) ([]history.Job, error) {
+	runs, err := m.pendingRuns()
+	if err != nil {
+		return nil, err
+	}
+	for _, jobs := range runs {
+		if jobs[0].Run == id {
+			return jobs, m.undoRun(jobs)
+		}
+	}
+	return nil, fmt.Errorf("already undone")
+}
+
+// pendingRuns groups jobs that haven't been undone by run, most recent first
+func (m *Manager) pendingRuns() ([][]history.Job, error) {
+	jobs, err := m.history.All()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	var runs [][]history.Job
+	for _, j := range jobs {
+		if j.Undone != nil {
+			continue
+		}
+		i, ok := index[j.Run]
+		if !ok {
+			i = len(runs)
+			index[j.Run] = i
+			runs = append(runs, nil)
+		}
+		runs[i] = append(runs[i], j)
+	}
+
+	sort.SliceStable(runs, func(a, b int) bool {
+		return runs[a][0].Start.After(runs[b][0].Start)
+	})
+	return runs, nil
+}
+
+// undoRun restores the original of a run's file, from its kept copy or
+// from the Trash, and moves the outputs its actions created to the trash.
+// Nothing is touched if the file was replaced and cannot be restored.
+func (m *Manager) undoRun(jobs []history.Job) error {
+	file := jobs[0].File
+	var backup, trashed string
+	replaced := false
+	for _, j := range jobs {
+		if backup == "" {
+			backup = j.Backup
+		}
+		if trashed == "" {
+			trashed = j.Trashed
+		}
+		replaced = replaced || j.Replaced
+	}
+	switch {
+	case backup != "":
+		if _, err := os.Stat(backup); err != nil {
+			return fmt.Errorf("original of %s is gone: %v", filepath.Base(file), err)
+		}
+	case trashed != "":
+		if _, err := os.Lstat(trashed); err != nil {
+			return fmt.Errorf("original of %s is no longer in the Trash at %s", filepath.Base(file), trashed)
+		}
+		if _, err := os.Lstat(file); err == nil {
+			return fmt.Errorf("cannot restore %s from the Trash at %s: a file with its name exists", filepath.Base(file), trashed)
+		}
+	case replaced:
+		return fmt.Errorf("no original kept for %s (add the action with -k)", filepath.Base(file))
+	}
+
+	// Keep the daemon from processing the restored file again
+	until := time.Now().Add(suppressFor)
+	m.outputMu.Lock()
+	m.recentOutputs[file] = time.Now()
+	m.outputMu.Unlock()
+	if err := m.history.Suppress(file, until); err != nil {
+		log.Printf("Warning: cannot suppress %s: %v", file, err)
+	}
+
+	// The original comes back first: if that fails, the outputs are all
+	// that is left of the file
+	switch {
+	case backup != "":
+		if err := fsutil.Copy(backup, file); err != nil {
+			return err
+		}
+	case trashed != "":
+		if err := trash.Restore(trashed, file); err != nil {
+			return fmt.Errorf("cannot restore %s from the Trash at %s: %w", filepath.Base(file), trashed, err)
+		}
+	}
+	for i := len(jobs) - 1; i >= 0; i-- {
+		for _, out := range jobs[i].Outputs {
+			if out == file {
+				continue
+			}
+			if _, err := trash.Put(out); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	run := jobs[0].Run
+	now := time.Now()
+	return m.history.Update(func(j *history.Job) {
+		if j.Run == run && j.Undone == nil {
+			j.Undone = &now
+		}
+	})
+}
+
+// discardPartial cleans up after a cancelled action: outputs named after
+// the file are deleted, and a file changed in place is restored from its
+// kept original. Other new files may have been added meanwhile by
+// someone else and are left alone.
+func (m *Manager) discardPartial(job *history.Job) {
+	stem := strings.TrimSuffix(filepath.Base(job.File), filepath.Ext(job.File))
+	var kept []string
+	for _, out := range job.Outputs {
+		info, err := os.Stat(out)
+		if err != nil {
+			continue
+		}
+		if !strings.HasPrefix(filepath.Base(out), stem) || info.ModTime().Before(job.Start) {
+			kept = append(kept, out)
+			continue
+		}
+		if err := os.Remove(out); err != nil {
+			log.Printf("Warning: cannot remove partial output %s: %v", out, err)
+			kept = append(kept, out)
+			continue
+		}
+		log.Printf("Removed partial output: %s", out)
+	}
+	job.Outputs = kept
+
+	if !job.Replaced || job.Backup == "" {
+		return
+	}
+	m.outputMu.Lock()
+	m.recentOutputs[job.File] = time.Now()
+	m.outputMu.Unlock()
+	if err := m.history.Suppress(job.File, time.Now().Add(suppressFor)); err != nil {
+		log.Printf("Warning: cannot suppress %s: %v", job.File, err)
+	}
+	if err := fsutil.Copy(job.Backup, job.File); err != nil {
+		log.Printf("Warning: cannot restore %s: %v", job.File, err)
+		return
+	}
+	job.Replaced = false
+	log.Printf("Restored original: %s", job.File)
+}
@@ -0,0 +1,148 @@
+package folders
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// convertInTrash runs convert-webp with a fake convert on a new file, so
+// the original goes to the Trash, and returns the file and where it went
+func convertInTrash(t *testing.T, m *Manager) (file, trashed string) {
+	t.Helper()
+	fakeBin(t, map[string]string{"convert": `cp "$1" "$(eval echo \${$#})"` + "\n"})
+	file = filepath.Join(t.TempDir(), "shot.png")
+	if err := os.WriteFile(file, []byte("png data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := m.ProcessFiles(context.Background(), []string{file}, FolderAction{Action: "convert-webp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Error != "" || jobs[0].Trashed == "" {
+		t.Fatalf("jobs = %+v, want one trashing its original", jobs)
+	}
+	return file, jobs[0].Trashed
+}
+
+func TestUndoFromTrash(t *testing.T) {
+	m := newTestManager(t)
+	file, trashed := convertInTrash(t, m)
+	info := filepath.Join(filepath.Dir(filepath.Dir(trashed)), "info", filepath.Base(trashed)+".trashinfo")
+	if _, err := os.Stat(info); err != nil {
+		t.Fatalf("no trashinfo for %s: %v", trashed, err)
+	}
+
+	if _, err := m.UndoFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "png data" {
+		t.Errorf("restored file = %q, %v", data, err)
+	}
+	for _, gone := range []string{trashed, info, strings.TrimSuffix(file, ".png") + ".webp"} {
+		if _, err := os.Stat(gone); !os.IsNotExist(err) {
+			t.Errorf("%s still exists", gone)
+		}
+	}
+	if _, err := m.UndoFile(file); err == nil {
+		t.Error("second undo succeeded")
+	}
+}
+
+func TestUndoFromTrashFails(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(file, trashed string)
+		errText string
+	}{
+		{"emptied trash", func(_, trashed string) { os.Remove(trashed) }, "no longer in the Trash"},
+		{"file recreated", func(file, _ string) { os.WriteFile(file, []byte("new"), 0644) }, "a file with its name exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			file, trashed := convertInTrash(t, m)
+			tt.prepare(file, trashed)
+			_, err := m.UndoFile(file)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) || !strings.Contains(err.Error(), trashed) {
+				t.Fatalf("UndoFile = %v, want error naming %s", err, trashed)
+			}
+			// Nothing was touched
+			if _, err := os.Stat(strings.TrimSuffix(file, ".png") + ".webp"); err != nil {
+				t.Errorf("output removed after a failed undo: %v", err)
+			}
+		})
+	}
+}
+
+// buildGato builds the gato command into PATH, for actions that run
+// gato trash like the presets do
+func buildGato(t *testing.T) {
+	t.Helper()
+	bin := t.TempDir()
+	out, err := exec.Command("go", "build", "-o", bin, "github.com/veinticinco/gato-daemon/cmd/gato").CombinedOutput()
+	if err != nil {
+		t.Fatalf("building gato: %v\n%s", err, out)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// TestUndoPresetFromTrash undoes a command that trashes the file with
+// gato trash, as the presets do, so only the subprocess knows where it went
+func TestUndoPresetFromTrash(t *testing.T) {
+	buildGato(t) // before HOME moves, so the build cache is used
+	m := newTestManager(t)
+	file := filepath.Join(t.TempDir(), "shot.png")
+	os.WriteFile(file, []byte("png data"), 0644)
+	output := strings.TrimSuffix(file, ".png") + ".webp"
+
+	jobs, err := m.ProcessFiles(context.Background(), []string{file}, FolderAction{Command: "cp {} {dir}/{name}.webp && gato trash {}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(os.Getenv("XDG_DATA_HOME"), "Trash", "files", "shot.png")
+	if len(jobs) != 1 || jobs[0].Error != "" || jobs[0].Trashed != want {
+		t.Fatalf("jobs = %+v, want one trashing its original to %s", jobs, want)
+	}
+
+	if _, err := m.UndoFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "png data" {
+		t.Errorf("restored file = %q, %v", data, err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("output still exists: %v", err)
+	}
+}
+
+// TestUndoKeepsOutputsWhenRestoreFails checks that outputs stay in place
+// when the original cannot be put back
+func TestUndoKeepsOutputsWhenRestoreFails(t *testing.T) {
+	m := newTestManager(t)
+	fakeBin(t, map[string]string{"convert": `cp "$1" "$(eval echo \${$#})"` + "\n"})
+	file := filepath.Join(t.TempDir(), "shot.png")
+	os.WriteFile(file, []byte("png data"), 0644)
+	output := strings.TrimSuffix(file, ".png") + ".webp"
+
+	jobs, err := m.ProcessFiles(context.Background(), []string{file}, FolderAction{Action: "convert-webp", KeepOriginal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Backup == "" {
+		t.Fatalf("jobs = %+v, want one keeping its original", jobs)
+	}
+	// A kept original that can't be read
+	os.Remove(jobs[0].Backup)
+	os.Mkdir(jobs[0].Backup, 0755)
+
+	if _, err := m.UndoFile(file); err == nil {
+		t.Fatal("undo succeeded without its original")
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Errorf("output removed after a failed restore: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package fsutil
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Copy copies src to dst, preserving mode, timestamps and extended
+// attributes. On btrfs and XFS the copy is a reflink sharing src's extents;
+// elsewhere the data is streamed. dst is written next to its final name and
+// renamed into place, so it is never seen half written.
+func Copy(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".gato-copy-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := cloneOrCopy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	copyXattrs(in, tmp)
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), accessTime(info), info.ModTime()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// cloneOrCopy tries FICLONE first. io.Copy between files uses
+// copy_file_range, which also avoids passing data through user space.
+func cloneOrCopy(dst, src *os.File) error {
+	if err := unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())); err == nil {
+		return nil
+	}
+	_, err := io.Copy(dst, src)
+	return err
+}
+
+// copyXattrs copies what it can; filesystems without xattr support and
+// attributes we may not set (e.g. security.*) are skipped
+func copyXattrs(src, dst *os.File) {
+	size, err := unix.Flistxattr(int(src.Fd()), nil)
+	if err != nil || size <= 0 {
+		return
+	}
+	names := make([]byte, size)
+	size, err = unix.Flistxattr(int(src.Fd()), names)
+	if err != nil {
+		return
+	}
+
+	for _, name := range splitNames(names[:size]) {
+		n, err := unix.Fgetxattr(int(src.Fd()), name, nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, n)
+		n, err = unix.Fgetxattr(int(src.Fd()), name, value)
+		if err != nil {
+			continue
+		}
+		unix.Fsetxattr(int(dst.Fd()), name, value[:n], 0)
+	}
+}
+
+// splitNames splits the NUL-separated list returned by listxattr
+func splitNames(buf []byte) []string {
+	var names []string
+	start := 0
+	for i, b := range buf {
+		if b == 0 {
+			if i > start {
+				names = append(names, string(buf[start:i]))
+			}
+			start = i + 1
+		}
+	}
+	return names
+}
+
+func accessTime(info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atim.Unix())
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,106 @@
+package fsutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestCopy(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "video.mov")
+	data := bytes.Repeat([]byte("0123456789abcdef"), 300000) // larger than any copy buffer
+	if err := os.WriteFile(src, data, 0640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	os.Chtimes(src, mtime, mtime)
+	xattrs := unix.Setxattr(src, "user.gato.test", []byte("kept"), 0) == nil
+
+	for _, dst := range []string{
+		filepath.Join(dir, "copy.mov"),
+		src + ".existing", // replaced
+	} {
+		os.WriteFile(src+".existing", []byte("old"), 0600)
+		if err := Copy(src, dst); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := os.ReadFile(dst)
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: %d bytes copied, want %d", dst, len(got), len(data))
+		}
+		info, err := os.Stat(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0640 || !info.ModTime().Equal(mtime) {
+			t.Errorf("%s: mode %v, mtime %v; want 0640, %v", dst, info.Mode().Perm(), info.ModTime(), mtime)
+		}
+		if xattrs {
+			value := make([]byte, 16)
+			n, err := unix.Getxattr(dst, "user.gato.test", value)
+			if err != nil || string(value[:n]) != "kept" {
+				t.Errorf("%s: xattr = %q, %v", dst, value[:n], err)
+			}
+		}
+	}
+
+	if err := Copy(filepath.Join(dir, "missing"), filepath.Join(dir, "out")); !os.IsNotExist(err) {
+		t.Errorf("Copy of a missing file = %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".gato-copy-") {
+			t.Errorf("temporary file left behind: %s", e.Name())
+		}
+	}
+}
+
+// TestCloneFallback copies from a pipe, which cannot be cloned, so the
+// data has to be streamed
+func TestCloneFallback(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		w.WriteString("streamed")
+		w.Close()
+	}()
+	defer r.Close()
+
+	dst, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if err := cloneOrCopy(dst, r); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dst.Name()); string(got) != "streamed" {
+		t.Errorf("copied %q", got)
+	}
+}
+
+func TestSplitNames(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"user.a\x00", []string{"user.a"}},
+		{"user.a\x00user.b\x00", []string{"user.a", "user.b"}},
+		{"user.a\x00\x00user.b\x00", []string{"user.a", "user.b"}},
+	}
+	for _, tt := range tests {
+		got := splitNames([]byte(tt.in))
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") || len(got) != len(tt.want) {
+			t.Errorf("splitNames(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package fsutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "folders.toml")
+
+	tests := []struct {
+		data string
+		perm os.FileMode
+	}{
+		{"first", 0644},
+		{"second, longer than the first", 0600},
+		{"", 0640},
+	}
+	for _, tt := range tests {
+		if err := WriteFile(path, []byte(tt.data), tt.perm); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := os.ReadFile(path)
+		info, _ := os.Stat(path)
+		if string(got) != tt.data || info.Mode().Perm() != tt.perm {
+			t.Errorf("wrote %q %v, want %q %v", got, info.Mode().Perm(), tt.data, tt.perm)
+		}
+	}
+
+	if err := WriteFile(filepath.Join(dir, "missing", "x"), nil, 0644); err == nil {
+		t.Error("WriteFile into a missing directory succeeded")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+// TestWriteFileReaders checks that a reader never sees a partial file
+func TestWriteFileReaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	a, b := make([]byte, 64<<10), make([]byte, 64<<10)
+	for i := range a {
+		a[i], b[i] = 'a', 'b'
+	}
+	WriteFile(path, a, 0644)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			data := a
+			if i%2 == 0 {
+				data = b
+			}
+			WriteFile(path, data, 0644)
+		}
+	}()
+	for range 200 {
+		got, err := os.ReadFile(path)
+		if err != nil || len(got) != len(a) || (got[0] != got[len(got)-1]) {
+			t.Fatalf("read %d bytes, %v", len(got), err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "folders.toml.lock")
+	unlock, err := Lock(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = Lock(path, 100*time.Millisecond)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("second Lock = %v, want ErrLocked", err)
+	}
+	if waited := time.Since(start); waited < 100*time.Millisecond {
+		t.Errorf("gave up after %v, before the timeout", waited)
+	}
+	if _, err := Lock(path, 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("Lock without waiting = %v, want ErrLocked", err)
+	}
+
+	// A waiting Lock gets the lock once it is released
+	got := make(chan error, 1)
+	go func() {
+		unlock, err := Lock(path, 5*time.Second)
+		if err == nil {
+			unlock()
+		}
+		got <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	unlock()
+	if err := <-got; err != nil {
+		t.Errorf("Lock after release = %v", err)
+	}
+
+	if _, err := Lock(filepath.Join(t.TempDir(), "missing", "x.lock"), 0); err == nil || errors.Is(err, ErrLocked) {
+		t.Errorf("Lock in a missing directory = %v", err)
+	}
+}
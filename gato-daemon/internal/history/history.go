@@ -0,0 +1,315 @@
+package history
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/veinticinco/gato-daemon/internal/fsutil"
+)
+
+// Job is one action run on one file
+type Job struct {
+	ID        string     `json:"id"`
+	Run       string     `json:"run"` // shared by every action run for the same file event
+	File      string     `json:"file"`
+	Folder    string     `json:"folder"`
+	ActionID  string     `json:"action_id"`
+	Action    string     `json:"action"` // predefined action name or command
+	Start     time.Time  `json:"start"`
+	End       time.Time  `json:"end"`
+	ExitCode  int        `json:"exit_code"` // -1 if the action failed without exiting
+	Error     string     `json:"error,omitempty"`
+	Before    int64      `json:"bytes_before"`      // size of the file before the action
+	After     int64      `json:"bytes_after"`       // size of the file and outputs afterwards
+	Backup    string     `json:"backup,omitempty"`  // kept original, if any
+	Trashed   string     `json:"trashed,omitempty"` // where the action moved the file in the Trash
+	Outputs   []string   `json:"outputs,omitempty"` // files the action created
+	Replaced  bool       `json:"replaced"`          // the action changed or removed the file itself
+	Cancelled bool       `json:"cancelled"`         // stopped with gato jobs cancel
+	Undone    *time.Time `json:"undone,omitempty"`  // nil until undone
+}
+
+// Job statuses
+const (
+	StatusOK        = "ok"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	StatusUndone    = "undone"
+)
+
+// Statuses lists the values accepted by Filter.Status
+var Statuses = []string{StatusOK, StatusFailed, StatusCancelled, StatusUndone}
+
+// Status returns ok, failed, cancelled or undone
+func (j Job) Status() string {
+	switch {
+	case j.Undone != nil:
+		return StatusUndone
+	case j.Cancelled:
+		return StatusCancelled
+	case j.Error != "":
+		return StatusFailed
+	default:
+		return StatusOK
+	}
+}
+
+// Filter selects jobs; zero fields match everything
+type Filter struct {
+	Folder string
+	Status string
+	Since  time.Time
+	Until  time.Time
+	Limit  int // most recent jobs only
+}
+
+// Query returns the jobs matching f, newest first
+func (s *Store) Query(f Filter) ([]Job, error) {
+	jobs, err := s.All()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []Job
+	for i := len(jobs) - 1; i >= 0; i-- {
+		j := jobs[i]
+		if f.Folder != "" && j.Folder != f.Folder {
+			continue
+		}
+		if f.Status != "" && j.Status() != f.Status {
+			continue
+		}
+		if !f.Since.IsZero() && j.Start.Before(f.Since) {
+			continue
+		}
+		if !f.Until.IsZero() && j.Start.After(f.Until) {
+			continue
+		}
+		matched = append(matched, j)
+		if f.Limit > 0 && len(matched) == f.Limit {
+			break
+		}
+	}
+	return matched, nil
+}
+
+// Retention limits how much history is kept; zero values mean no limit.
+// Runs are kept or dropped whole, so undo never sees half a run.
+type Retention struct {
+	MaxAge  time.Duration
+	MaxJobs int
+}
+
+// DefaultRetention keeps about three months of a busy folder
+var DefaultRetention = Retention{MaxAge: 90 * 24 * time.Hour, MaxJobs: 20000}
+
+// Store is an append-only JSON lines journal of jobs, shared by the daemon
+// and the CLI. Writers take an exclusive advisory lock on the file and
+// readers a shared one. Old jobs are dropped whenever the journal is
+// rewritten, see Prune.
+type Store struct {
+	dir  string
+	keep Retention
+}
+
+// New creates a store in dir with the default retention
+func New(dir string) *Store {
+	return &Store{dir: dir, keep: DefaultRetention}
+}
+
+// Open creates a store in dir that keeps what keep allows
+func Open(dir string, keep Retention) *Store {
+	return &Store{dir: dir, keep: keep}
+}
+
+func (s *Store) path() string {
+	return filepath.Join(s.dir, "history.jsonl")
+}
+
+// NewID returns a short random ID for jobs and runs
+func NewID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withLock runs fn with a lock on the journal, syscall.LOCK_SH for readers
+// or syscall.LOCK_EX for writers
+func (s *Store) withLock(flag, how int, fn func(f *os.File) error) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path(), flag|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return fn(f)
+}
+
+// Add appends a job to the journal
+func (s *Store) Add(job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.withLock(os.O_RDWR|os.O_APPEND, syscall.LOCK_EX, func(f *os.File) error {
+		// Start a new line if a crash cut the last one short, or this job
+		// would be lost with it
+		if info, err := f.Stat(); err == nil && info.Size() > 0 {
+			last := make([]byte, 1)
+			if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+				data = append([]byte{'\n'}, data...)
+			}
+		}
+		_, err := f.Write(append(data, '\n'))
+		return err
+	})
+}
+
+// All returns every job, oldest first
+func (s *Store) All() ([]Job, error) {
+	var jobs []Job
+	err := s.withLock(os.O_RDONLY, syscall.LOCK_SH, func(f *os.File) error {
+		var err error
+		jobs, err = readJobs(f)
+		return err
+	})
+	return jobs, err
+}
+
+// Update rewrites the journal with edit applied to every job, dropping
+// the jobs the retention no longer allows
+func (s *Store) Update(edit func(*Job)) error {
+	_, err := s.update(edit)
+	return err
+}
+
+// Prune applies the retention now and reports how many jobs it dropped
+func (s *Store) Prune() (removed int, err error) {
+	return s.update(func(*Job) {})
+}
+
+func (s *Store) update(edit func(*Job)) (removed int, err error) {
+	err = s.withLock(os.O_RDWR, syscall.LOCK_EX, func(f *os.File) error {
+		jobs, err := readJobs(f)
+		if err != nil {
+			return err
+		}
+		kept := s.retain(jobs, time.Now())
+		removed = len(jobs) - len(kept)
+
+		var buf []byte
+		for i := range kept {
+			edit(&kept[i])
+			data, err := json.Marshal(kept[i])
+			if err != nil {
+				return err
+			}
+			buf = append(append(buf, data...), '\n')
+		}
+
+		// Rewrite in place: other processes wait on the lock of this inode,
+		// so replacing the file would lose their appends
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		_, err = f.WriteAt(buf, 0)
+		return err
+	})
+	return removed, err
+}
+
+// retain drops the runs of jobs that are too old or beyond the most
+// recent MaxJobs. Jobs are in the order they were added.
+func (s *Store) retain(jobs []Job, now time.Time) []Job {
+	dropped := make(map[string]bool)
+	for i, j := range jobs {
+		tooMany := s.keep.MaxJobs > 0 && i < len(jobs)-s.keep.MaxJobs
+		tooOld := s.keep.MaxAge > 0 && now.Sub(j.Start) > s.keep.MaxAge
+		if tooMany || tooOld {
+			dropped[j.Run] = true
+		}
+	}
+	if len(dropped) == 0 {
+		return jobs
+	}
+	var kept []Job
+	for _, j := range jobs {
+		if !dropped[j.Run] {
+			kept = append(kept, j)
+		}
+	}
+	return kept
+}
+
+func readJobs(f *os.File) ([]Job, error) {
+	var jobs []Job
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var job Job
+		// Skip lines cut short by a crash
+		if err := json.Unmarshal(scanner.Bytes(), &job); err == nil {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, scanner.Err()
+}
+
+// Suppress asks the daemon not to process path until the given time.
+// Used after undo so restored files aren't picked up again.
+func (s *Store) Suppress(path string, until time.Time) error {
+	return s.withSuppressions(func(list map[string]time.Time) {
+		list[path] = until
+	})
+}
+
+// Suppressed reports whether path should be skipped right now
+func (s *Store) Suppressed(path string) bool {
+	data, err := os.ReadFile(filepath.Join(s.dir, "suppress.json"))
+	if err != nil {
+		return false
+	}
+	var list map[string]time.Time
+	if json.Unmarshal(data, &list) != nil {
+		return false
+	}
+	until, ok := list[path]
+	return ok && time.Now().Before(until)
+}
+
+func (s *Store) withSuppressions(edit func(map[string]time.Time)) error {
+	return s.withLock(os.O_RDONLY, syscall.LOCK_EX, func(*os.File) error {
+		path := filepath.Join(s.dir, "suppress.json")
+		list := make(map[string]time.Time)
+		if data, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(data, &list)
+		}
+
+		edit(list)
+		for p, until := range list {
+			if time.Now().After(until) {
+				delete(list, p)
+			}
+		}
+
+		data, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+		// Suppressed reads without the lock
+		return fsutil.WriteFile(path, data, 0644)
+	})
+}
@@ -0,0 +1,243 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	s := New(t.TempDir())
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	undone := base.Add(time.Hour)
+	jobs := []Job{
+		{ID: "1", Folder: "/a", Start: base},
+		{ID: "2", Folder: "/b", Start: base.Add(1 * time.Minute), Error: "exit status 1"},
+		{ID: "3", Folder: "/a", Start: base.Add(2 * time.Minute), Cancelled: true, Error: "cancelled"},
+		{ID: "4", Folder: "/a", Start: base.Add(3 * time.Minute), Undone: &undone},
+		{ID: "5", Folder: "/b", Start: base.Add(4 * time.Minute)},
+	}
+	for _, j := range jobs {
+		if err := s.Add(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string // IDs, newest first
+	}{
+		{"all", Filter{}, "54321"},
+		{"folder", Filter{Folder: "/a"}, "431"},
+		{"ok", Filter{Status: StatusOK}, "51"},
+		{"failed", Filter{Status: StatusFailed}, "2"},
+		{"cancelled", Filter{Status: StatusCancelled}, "3"},
+		{"undone", Filter{Status: StatusUndone}, "4"},
+		{"since", Filter{Since: base.Add(2 * time.Minute)}, "543"},
+		{"until", Filter{Until: base.Add(time.Minute)}, "21"},
+		{"range", Filter{Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)}, "432"},
+		{"limit", Filter{Limit: 2}, "54"},
+		{"limit after filter", Filter{Folder: "/a", Limit: 2}, "43"},
+		{"no match", Filter{Folder: "/c"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, err := s.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			for _, j := range matched {
+				got += j.ID
+			}
+			if got != tt.want {
+				t.Errorf("Query(%+v) = %s, want %s", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+	start := time.Now()
+	s.Add(Job{ID: "1", Run: "r1", Start: start})
+	s.Add(Job{ID: "2", Run: "r2", Start: start})
+
+	// A line cut short by a crash is skipped
+	f, _ := os.OpenFile(filepath.Join(dir, "history.jsonl"), os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"id":"3","run":`)
+	f.Close()
+	s.Add(Job{ID: "4", Run: "r1", Start: start})
+
+	now := time.Now()
+	if err := s.Update(func(j *Job) {
+		if j.Run == "r1" {
+			j.Undone = &now
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := s.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, j := range jobs {
+		got = append(got, j.ID+":"+j.Status())
+	}
+	if want := "1:undone 2:ok 4:undone"; strings.Join(got, " ") != want {
+		t.Errorf("jobs = %v, want %s", got, want)
+	}
+
+	// Jobs that were not undone have no undone field at all
+	data, _ := os.ReadFile(filepath.Join(dir, "history.jsonl"))
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var fields map[string]any
+		json.Unmarshal([]byte(line), &fields)
+		if _, ok := fields["undone"]; ok != (fields["run"] == "r1") {
+			t.Errorf("undone field in %s", line)
+		}
+	}
+}
+
+func TestSuppress(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Now()
+	tests := []struct {
+		path  string
+		until time.Time
+		want  bool
+	}{
+		{"/a/restored.png", now.Add(time.Minute), true},
+		{"/a/expired.png", now.Add(-time.Second), false},
+		{"/a/other.png", time.Time{}, false}, // never suppressed
+	}
+	for _, tt := range tests {
+		if !tt.until.IsZero() {
+			if err := s.Suppress(tt.path, tt.until); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, tt := range tests {
+		if got := s.Suppressed(tt.path); got != tt.want {
+			t.Errorf("Suppressed(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// Expired entries are dropped on the next write
+	data, _ := os.ReadFile(filepath.Join(s.dir, "suppress.json"))
+	if strings.Contains(string(data), "expired") {
+		t.Errorf("expired entry kept: %s", data)
+	}
+	if New(t.TempDir()).Suppressed("/a/restored.png") {
+		t.Error("empty store suppresses files")
+	}
+}
+
+func TestRetain(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	jobs := []Job{
+		{ID: "1", Run: "a", Start: now.Add(-40 * day)},
+		{ID: "2", Run: "b", Start: now.Add(-20 * day)},
+		{ID: "3", Run: "b", Start: now.Add(-20 * day)}, // second action of run b
+		{ID: "4", Run: "c", Start: now.Add(-2 * day)},
+		{ID: "5", Run: "d", Start: now.Add(-1 * day)},
+		{ID: "6", Run: "c", Start: now.Add(-2 * day)}, // finished after d
+	}
+	tests := []struct {
+		name string
+		keep Retention
+		want string
+	}{
+		{"no limits", Retention{}, "123456"},
+		{"max age", Retention{MaxAge: 30 * day}, "23456"},
+		{"max age keeps nothing", Retention{MaxAge: time.Hour}, ""},
+		{"max jobs", Retention{MaxJobs: 5}, "23456"},
+		{"max jobs splitting a run", Retention{MaxJobs: 4}, "456"},
+		{"max jobs splitting the newest run", Retention{MaxJobs: 1}, ""},
+		{"both", Retention{MaxAge: 30 * day, MaxJobs: 10}, "23456"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Open(t.TempDir(), tt.keep)
+			var got string
+			for _, j := range s.retain(jobs, now) {
+				got += j.ID
+			}
+			if got != tt.want {
+				t.Errorf("retain = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := Open(t.TempDir(), Retention{MaxJobs: 2})
+	for _, id := range []string{"1", "2", "3"} {
+		s.Add(Job{ID: id, Run: id, Start: time.Now()})
+	}
+	// Appending never prunes, so adding stays cheap
+	if jobs, _ := s.All(); len(jobs) != 3 {
+		t.Errorf("%d jobs before pruning, want 3", len(jobs))
+	}
+
+	removed, err := s.Prune()
+	if err != nil || removed != 1 {
+		t.Errorf("Prune = %d, %v; want 1", removed, err)
+	}
+	jobs, _ := s.All()
+	if len(jobs) != 2 || jobs[0].ID != "2" {
+		t.Errorf("jobs after Prune = %+v", jobs)
+	}
+	if removed, err := s.Prune(); err != nil || removed != 0 {
+		t.Errorf("second Prune = %d, %v", removed, err)
+	}
+}
+
+// TestReadersShareLock checks that All doesn't wait for other readers,
+// only for writers
+func TestReadersShareLock(t *testing.T) {
+	s := New(t.TempDir())
+	s.Add(Job{ID: "1", Run: "1", Start: time.Now()})
+
+	tests := []struct {
+		how  int
+		wait bool
+	}{
+		{syscall.LOCK_SH, false},
+		{syscall.LOCK_EX, true},
+	}
+	for _, tt := range tests {
+		f, err := os.Open(s.path())
+		if err != nil {
+			t.Fatal(err)
+		}
+		syscall.Flock(int(f.Fd()), tt.how)
+
+		done := make(chan struct{})
+		go func() {
+			s.All()
+			close(done)
+		}()
+		select {
+		case <-done:
+			if tt.wait {
+				t.Error("All read while a writer held the lock")
+			}
+		case <-time.After(200 * time.Millisecond):
+			if !tt.wait {
+				t.Error("All waited for another reader")
+			}
+		}
+		f.Close() // releases the lock
+		<-done
+	}
+}
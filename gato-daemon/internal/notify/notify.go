@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	busName    = "org.freedesktop.Notifications"
+	objectPath = "/org/freedesktop/Notifications"
+	iface      = "org.freedesktop.Notifications"
+)
+
+// Urgency levels from the notification spec
+const (
+	UrgencyLow      byte = 0
+	UrgencyNormal   byte = 1
+	UrgencyCritical byte = 2
+)
+
+// Action is a button on a notification. The "default" key is invoked when
+// the notification body itself is clicked.
+type Action struct {
+	Key   string
+	Label string
+	Run   func()
+}
+
+// Notification is a desktop notification with optional actions
+type Notification struct {
+	Summary    string
+	Body       string
+	Icon       string
+	Urgency    byte
+	ReplacesID uint32 // update an existing notification in place
+	Timeout    int32  // milliseconds, -1 = server default
+	Progress   int    // percent shown as a progress bar, 0 = none
+	Actions    []Action
+}
+
+// Notifier sends notifications over D-Bus and dispatches their actions
+type Notifier struct {
+	conn    *dbus.Conn
+	obj     dbus.BusObject
+	signals chan *dbus.Signal
+
+	mu      sync.Mutex
+	actions map[uint32]map[string]func() // notification ID -> action key -> callback
+}
+
+// New connects to the session bus
+func New() (*Notifier, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to session bus: %w", err)
+	}
+	return NewWithConn(conn)
+}
+
+// NewWithConn uses an existing connection, e.g. to a private dbus-daemon
+func NewWithConn(conn *dbus.Conn) (*Notifier, error) {
+	n := &Notifier{
+		conn:    conn,
+		obj:     conn.Object(busName, objectPath),
+		signals: make(chan *dbus.Signal, 16),
+		actions: make(map[uint32]map[string]func()),
+	}
+
+	for _, member := range []string{"ActionInvoked", "NotificationClosed"} {
+		if err := conn.AddMatchSignal(
+			dbus.WithMatchObjectPath(objectPath),
+			dbus.WithMatchInterface(iface),
+			dbus.WithMatchMember(member),
+		); err != nil {
+			return nil, fmt.Errorf("cannot subscribe to %s: %w", member, err)
+		}
+	}
+	conn.Signal(n.signals)
+	go n.dispatch()
+
+	return n, nil
+}
+
+// Close disconnects from the bus
+func (n *Notifier) Close() error {
+	n.conn.RemoveSignal(n.signals)
+	return n.conn.Close()
+}
+
+// Notify shows a notification and returns its ID
+func (n *Notifier) Notify(note Notification) (uint32, error) {
+	var actions []string
+	for _, a := range note.Actions {
+		actions = append(actions, a.Key, a.Label)
+	}
+
+	timeout := note.Timeout
+	if timeout == 0 {
+		timeout = -1
+	}
+	hints := map[string]dbus.Variant{
+		"urgency": dbus.MakeVariant(note.Urgency),
+	}
+	if note.Progress > 0 {
+		hints["value"] = dbus.MakeVariant(int32(note.Progress))
+	}
+
+	var id uint32
+	call := n.obj.Call(iface+".Notify", 0,
+		"Gato", note.ReplacesID, note.Icon, note.Summary, note.Body,
+		actions, hints, timeout)
+	if err := call.Store(&id); err != nil {
+		return 0, err
+	}
+
+	n.mu.Lock()
+	if len(note.Actions) > 0 {
+		handlers := make(map[string]func())
+		for _, a := range note.Actions {
+			handlers[a.Key] = a.Run
+		}
+		n.actions[id] = handlers
+	} else {
+		delete(n.actions, id)
+	}
+	n.mu.Unlock()
+
+	return id, nil
+}
+
+// Dismiss closes a notification
+func (n *Notifier) Dismiss(id uint32) error {
+	n.mu.Lock()
+	delete(n.actions, id)
+	n.mu.Unlock()
+	return n.obj.Call(iface+".CloseNotification", 0, id).Err
+}
+
+// dispatch runs action callbacks and forgets closed notifications
+func (n *Notifier) dispatch() {
+	for sig := range n.signals {
+		if len(sig.Body) < 2 {
+			continue
+		}
+		id, ok := sig.Body[0].(uint32)
+		if !ok {
+			continue
+		}
+
+		switch sig.Name {
+		case iface + ".ActionInvoked":
+			key, _ := sig.Body[1].(string)
+			n.mu.Lock()
+			run := n.actions[id][key]
+			n.mu.Unlock()
+			if run != nil {
+				go run()
+			}
+		case iface + ".NotificationClosed":
+			n.mu.Lock()
+			delete(n.actions, id)
+			n.mu.Unlock()
+		}
+	}
+}
+
+// ShowInFolder asks the file manager to reveal a file, falling back to
+// opening its directory
+func (n *Notifier) ShowInFolder(path string) {
+	uri := (&url.URL{Scheme: "file", Path: path}).String()
+	fm := n.conn.Object("org.freedesktop.FileManager1", "/org/freedesktop/FileManager1")
+	if err := fm.Call("org.freedesktop.FileManager1.ShowItems", 0, []string{uri}, "").Err; err != nil {
+		Open(filepath.Dir(path))
+	}
+}
+
+// Open opens a file or folder with the default application. xdg-open is
+// waited for in the background so the daemon is not left with zombies.
+func Open(path string) {
+	cmd := exec.Command("xdg-open", path)
+	if cmd.Start() == nil {
+		go cmd.Wait()
+	}
+}
@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/veinticinco/gato-daemon/internal/dbustest"
+)
+
+// server is a fake notification daemon
+type server struct {
+	conn *dbus.Conn
+
+	mu      sync.Mutex
+	next    uint32
+	actions []string
+	hints   map[string]dbus.Variant
+	closed  []uint32
+}
+
+func (s *server) Notify(app string, replaces uint32, icon, summary, body string, actions []string, hints map[string]dbus.Variant, timeout int32) (uint32, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions, s.hints = actions, hints
+	if replaces != 0 {
+		return replaces, nil
+	}
+	s.next++
+	return s.next, nil
+}
+
+func (s *server) CloseNotification(id uint32) *dbus.Error {
+	s.mu.Lock()
+	s.closed = append(s.closed, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// emit sends a signal as the notification daemon would
+func (s *server) emit(t *testing.T, member string, args ...any) {
+	t.Helper()
+	if err := s.conn.Emit(objectPath, iface+"."+member, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func startServer(t *testing.T) (*server, *Notifier) {
+	t.Helper()
+	address := dbustest.Start(t)
+	s := &server{conn: dbustest.Connect(t, address)}
+	if err := s.conn.Export(s, objectPath, iface); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := s.conn.RequestName(busName, dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("cannot own %s: %v", busName, err)
+	}
+
+	n, err := NewWithConn(dbustest.Connect(t, address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, n
+}
+
+func TestActionInvoked(t *testing.T) {
+	s, n := startServer(t)
+
+	ran := make(chan string, 2)
+	id, err := n.Notify(Notification{
+		Summary:  "Processed: a.png",
+		Progress: 40,
+		Actions: []Action{
+			{Key: "default", Label: "Open", Run: func() { ran <- "default" }},
+			{Key: "undo", Label: "Undo", Run: func() { ran <- "undo" }},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	actions, hints := strings.Join(s.actions, ","), s.hints
+	s.mu.Unlock()
+	if actions != "default,Open,undo,Undo" {
+		t.Errorf("actions = %s", actions)
+	}
+	if v, ok := hints["value"]; !ok || v.Value() != int32(40) {
+		t.Errorf("progress hint = %v", hints["value"])
+	}
+
+	// Signals for other notifications are ignored
+	s.emit(t, "ActionInvoked", id+1, "undo")
+	s.emit(t, "ActionInvoked", id, "undo")
+	select {
+	case key := <-ran:
+		if key != "undo" {
+			t.Errorf("ran %s, want undo", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("undo action did not run")
+	}
+
+	// Closed notifications forget their actions
+	s.emit(t, "NotificationClosed", id, uint32(2))
+	s.emit(t, "ActionInvoked", id, "default")
+	select {
+	case key := <-ran:
+		t.Errorf("%s ran after the notification was closed", key)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestDismiss(t *testing.T) {
+	s, n := startServer(t)
+	ran := make(chan struct{}, 1)
+	id, err := n.Notify(Notification{Summary: "Processing", Actions: []Action{
+		{Key: "cancel", Label: "Cancel", Run: func() { ran <- struct{}{} }},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Dismiss(id); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if len(closed) != 1 || closed[0] != id {
+		t.Errorf("closed = %v, want [%d]", closed, id)
+	}
+
+	s.emit(t, "ActionInvoked", id, "cancel")
+	select {
+	case <-ran:
+		t.Error("cancel ran after Dismiss")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// TestOpenReapsChild checks that xdg-open does not stay behind as a zombie
+func TestOpenReapsChild(t *testing.T) {
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "xdg-open"), []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	for range 3 {
+		Open("/tmp")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for zombies() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d zombie children left", zombies())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// zombies counts this process's children that exited but were not waited for
+func zombies() int {
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	count := 0
+	for _, path := range stats {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// pid (comm) state ppid ...
+		fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+		if len(fields) < 2 {
+			continue
+		}
+		if ppid, _ := strconv.Atoi(fields[1]); ppid == os.Getpid() && fields[0] == "Z" {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,367 @@
+package originals
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/veinticinco/gato-daemon/internal/fsutil"
+)
+
+// Version is one stored copy of a file
+type Version struct {
+	Name string    `json:"name"` // base name of the file when it was stored
+	Hash string    `json:"hash"` // sha256 of the content
+	Size int64     `json:"size"`
+	Time time.Time `json:"time"`
+
+	// Migrated marks copies imported from the old one-file-per-name layout.
+	// Their time is the old file's mtime, so they are never pruned.
+	Migrated bool `json:"migrated,omitempty"`
+}
+
+// Retention limits what a store keeps; zero values mean no limit. The most
+// recent version of each file is always kept so its last change can be
+// undone, and migrated copies are never removed.
+type Retention struct {
+	MaxAge  time.Duration
+	MaxSize int64 // bytes of unique content
+}
+
+// DefaultRetention applies to folders that don't set their own
+var DefaultRetention = Retention{MaxAge: 30 * 24 * time.Hour}
+
+// Store is a folder's .originals directory. Content lives once under
+// objects/ keyed by its hash; index.jsonl lists every stored version.
+type Store struct {
+	dir  string
+	keep Retention
+}
+
+// Open returns the store in dir, created on first use
+func Open(dir string, keep Retention) *Store {
+	return &Store{dir: dir, keep: keep}
+}
+
+// ObjectPath is where the content with the given hash is stored
+func (s *Store) ObjectPath(hash string) string {
+	return filepath.Join(s.dir, "objects", hash[:2], hash)
+}
+
+// Put stores a copy of path. Identical content is only stored once.
+func (s *Store) Put(path string) (Version, error) {
+	hash, size, err := hashFile(path)
+	if err != nil {
+		return Version{}, err
+	}
+	v := Version{Name: filepath.Base(path), Hash: hash, Size: size, Time: time.Now()}
+
+	err = s.withLock(func(f *os.File) error {
+		obj := s.ObjectPath(hash)
+		if _, err := os.Stat(obj); os.IsNotExist(err) {
+			if err := os.MkdirAll(filepath.Dir(obj), 0755); err != nil {
+				return err
+			}
+			if err := fsutil.Copy(path, obj); err != nil {
+				return err
+			}
+		}
+
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		end, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		// Start a new line if a crash cut the last one short; otherwise
+		// this version would be unreadable and its content pruned
+		last := make([]byte, 1)
+		if end > 0 {
+			if _, err := f.ReadAt(last, end-1); err == nil && last[0] != '\n' {
+				data = append([]byte{'\n'}, data...)
+			}
+		}
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			return err
+		}
+		_, _, err = s.prune(f)
+		return err
+	})
+	return v, err
+}
+
+// Versions lists stored versions, oldest first
+func (s *Store) Versions() ([]Version, error) {
+	var versions []Version
+	err := s.withLock(func(f *os.File) error {
+		var err error
+		versions, err = readIndex(f)
+		return err
+	})
+	return versions, err
+}
+
+// Prune applies the retention policy now and reports what it removed
+func (s *Store) Prune() (removed int, freed int64, err error) {
+	err = s.withLock(func(f *os.File) error {
+		removed, freed, err = s.prune(f)
+		return err
+	})
+	return removed, freed, err
+}
+
+// prune drops expired versions, then the oldest ones while the store is
+// over its size limit, and deletes content no version refers to
+func (s *Store) prune(f *os.File) (int, int64, error) {
+	versions, err := readIndex(f)
+	if err != nil || len(versions) == 0 {
+		return 0, 0, err
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].Time.Before(versions[j].Time) })
+	newest := make(map[string]int)
+	for i, v := range versions {
+		newest[v.Name] = i
+	}
+	protected := func(i int) bool {
+		return newest[versions[i].Name] == i || versions[i].Migrated
+	}
+
+	keep := make([]bool, len(versions))
+	for i, v := range versions {
+		keep[i] = protected(i) || s.keep.MaxAge == 0 || time.Since(v.Time) <= s.keep.MaxAge
+	}
+	if s.keep.MaxSize > 0 {
+		for i := range versions {
+			if uniqueSize(versions, keep) <= s.keep.MaxSize {
+				break
+			}
+			if !protected(i) {
+				keep[i] = false
+			}
+		}
+	}
+	var kept []Version
+	for i, v := range versions {
+		if keep[i] {
+			kept = append(kept, v)
+		}
+	}
+	removed := len(versions) - len(kept)
+	if removed > 0 {
+		if err := writeIndex(f, kept); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// Collect content that is no longer referenced
+	referenced := make(map[string]bool)
+	for _, v := range kept {
+		referenced[v.Hash] = true
+	}
+	var freed int64
+	objects, _ := filepath.Glob(filepath.Join(s.dir, "objects", "*", "*"))
+	for _, obj := range objects {
+		if referenced[filepath.Base(obj)] {
+			continue
+		}
+		if info, err := os.Stat(obj); err == nil {
+			freed += info.Size()
+		}
+		os.Remove(obj)
+	}
+	return removed, freed, nil
+}
+
+// uniqueSize adds up the content of the versions marked in keep
+func uniqueSize(versions []Version, keep []bool) int64 {
+	seen := make(map[string]bool)
+	var total int64
+	for i, v := range versions {
+		if keep[i] && !seen[v.Hash] {
+			seen[v.Hash] = true
+			total += v.Size
+		}
+	}
+	return total
+}
+
+// withLock runs fn with an exclusive lock on the index. Copies kept by
+// older versions, one file per base name, are imported first.
+func (s *Store) withLock(fn func(f *os.File) error) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, "index.jsonl"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	if err := s.migrate(f); err != nil {
+		return err
+	}
+	return fn(f)
+}
+
+// migrate moves plain files from the old .originals layout into the store
+func (s *Store) migrate(f *os.File) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() || e.Name() == "index.jsonl" || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(s.dir, e.Name())
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		hash, size, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		obj := s.ObjectPath(hash)
+		if err := os.MkdirAll(filepath.Dir(obj), 0755); err != nil {
+			return err
+		}
+		if _, err := os.Stat(obj); os.IsNotExist(err) {
+			if err := os.Rename(path, obj); err != nil {
+				return err
+			}
+		} else {
+			os.Remove(path)
+		}
+
+		data, _ := json.Marshal(Version{Name: e.Name(), Hash: hash, Size: size, Time: info.ModTime(), Migrated: true})
+		f.Seek(0, io.SeekEnd)
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readIndex(f *os.File) ([]Version, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var versions []Version
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var v Version
+		if err := json.Unmarshal(scanner.Bytes(), &v); err == nil && len(v.Hash) > 2 {
+			versions = append(versions, v)
+		}
+	}
+	return versions, scanner.Err()
+}
+
+func writeIndex(f *os.File, versions []Version) error {
+	var buf []byte
+	for _, v := range versions {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt(buf, 0)
+	return err
+}
+
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// ParseAge parses a retention age such as 30d, 2w or 12h. "0" keeps forever.
+func ParseAge(s string) (time.Duration, error) {
+	if s == "0" {
+		return 0, nil
+	}
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			days, err := strconv.ParseFloat(n, 64)
+			if err != nil || days < 0 {
+				return 0, fmt.Errorf("invalid age: %s", s)
+			}
+			return time.Duration(days * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age: %s (use e.g. 30d, 2w, 12h)", s)
+	}
+	return d, nil
+}
+
+// ParseSize parses a size such as 500M, 2G or 1.5GB. "0" means no limit.
+func ParseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		mult   float64
+	}{
+		{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40}, {"B", 1},
+	}
+
+	n := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	mult := 1.0
+	for _, u := range units {
+		if rest, ok := strings.CutSuffix(n, u.suffix); ok {
+			n, mult = rest, u.mult
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(n, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size: %s (use e.g. 500M, 2G)", s)
+	}
+	return int64(value * mult), nil
+}
+
+// FormatSize formats bytes for listings, e.g. 1.5G
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,292 @@
+package originals
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// writeFile creates a file with the given content and modification time
+func writeFile(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// seed stores versions with made-up times directly in the index
+func seed(t *testing.T, s *Store, versions ...Version) {
+	t.Helper()
+	var data []byte
+	for i, v := range versions {
+		src := filepath.Join(t.TempDir(), v.Name)
+		writeFile(t, src, v.Name+string(rune('a'+i)), v.Time)
+		hash, size, err := hashFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, s.ObjectPath(hash), v.Name+string(rune('a'+i)), v.Time)
+		v.Hash, v.Size = hash, size
+		line, _ := json.Marshal(v)
+		data = append(append(data, line...), '\n')
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, "index.jsonl"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func names(versions []Version) []string {
+	var list []string
+	for _, v := range versions {
+		list = append(list, v.Name+"@"+v.Time.Format("2006-01-02"))
+	}
+	sort.Strings(list)
+	return list
+}
+
+func TestMigratedCopiesSurvivePruning(t *testing.T) {
+	folder := t.TempDir()
+	dir := filepath.Join(folder, ".originals")
+	old := time.Now().AddDate(-1, 0, 0)
+	writeFile(t, filepath.Join(dir, "a.png"), "old a", old)
+	writeFile(t, filepath.Join(dir, "b.png"), "old b", old)
+
+	s := Open(dir, DefaultRetention)
+	writeFile(t, filepath.Join(folder, "c.png"), "new c", time.Now())
+	if _, err := s.Put(filepath.Join(folder, "c.png")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Prune(); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := s.Versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("versions = %v, want a.png, b.png and c.png", names(versions))
+	}
+	for _, v := range versions {
+		if v.Migrated != (v.Name != "c.png") {
+			t.Errorf("%s: migrated = %v", v.Name, v.Migrated)
+		}
+		if _, err := os.Stat(s.ObjectPath(v.Hash)); err != nil {
+			t.Errorf("%s: content missing: %v", v.Name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.png")); !os.IsNotExist(err) {
+		t.Errorf("a.png left in the old layout")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Now()
+	at := func(daysAgo int) time.Time { return now.Add(-time.Duration(daysAgo) * day) }
+
+	tests := []struct {
+		name     string
+		keep     Retention
+		versions []Version
+		want     []string
+	}{
+		{
+			name: "age keeps the newest version of each file",
+			keep: Retention{MaxAge: 30 * day},
+			versions: []Version{
+				{Name: "a", Time: at(90)}, {Name: "a", Time: at(60)},
+				{Name: "b", Time: at(80)}, {Name: "c", Time: at(5)},
+			},
+			want: []string{"a@" + at(60).Format("2006-01-02"), "b@" + at(80).Format("2006-01-02"), "c@" + at(5).Format("2006-01-02")},
+		},
+		{
+			name:     "no limit",
+			keep:     Retention{},
+			versions: []Version{{Name: "a", Time: at(900)}, {Name: "a", Time: at(1)}},
+			want:     []string{"a@" + at(900).Format("2006-01-02"), "a@" + at(1).Format("2006-01-02")},
+		},
+		{
+			name: "size drops the oldest first",
+			keep: Retention{MaxSize: 4},
+			versions: []Version{
+				{Name: "a", Time: at(3)}, {Name: "a", Time: at(2)}, {Name: "a", Time: at(1)},
+			},
+			want: []string{"a@" + at(2).Format("2006-01-02"), "a@" + at(1).Format("2006-01-02")},
+		},
+		{
+			name: "migrated copies are exempt",
+			keep: Retention{MaxAge: day, MaxSize: 1},
+			versions: []Version{
+				{Name: "a", Time: at(400), Migrated: true}, {Name: "a", Time: at(10)}, {Name: "a", Time: at(2)},
+			},
+			want: []string{"a@" + at(400).Format("2006-01-02"), "a@" + at(2).Format("2006-01-02")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Open(filepath.Join(t.TempDir(), ".originals"), tt.keep)
+			seed(t, s, tt.versions...)
+			removed, _, err := s.Prune()
+			if err != nil {
+				t.Fatal(err)
+			}
+			versions, _ := s.Versions()
+			got := names(versions)
+			sort.Strings(tt.want)
+			if len(got) != len(tt.want) || removed != len(tt.versions)-len(tt.want) {
+				t.Fatalf("kept %v (removed %d), want %v", got, removed, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("kept %v, want %v", got, tt.want)
+				}
+			}
+			objects, _ := filepath.Glob(filepath.Join(s.dir, "objects", "*", "*"))
+			if len(objects) != len(tt.want) {
+				t.Errorf("%d objects left, want %d", len(objects), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"30d", 30 * day, true},
+		{"0d", 0, true},
+		{"1.5d", 36 * time.Hour, true},
+		{"2w", 14 * day, true},
+		{"12h", 12 * time.Hour, true},
+		{"90m", 90 * time.Minute, true},
+		{"", 0, false},
+		{"d", 0, false},
+		{"30", 0, false},
+		{"-1d", 0, false},
+		{"-2h", 0, false},
+		{"1y", 0, false},
+		{"thirty days", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"1024", 1024, true},
+		{"100B", 100, true},
+		{"2K", 2 << 10, true},
+		{"500M", 500 << 20, true},
+		{"500mb", 500 << 20, true},
+		{" 2G ", 2 << 30, true},
+		{"1.5GB", 3 << 29, true},
+		{"1T", 1 << 40, true},
+		{"", 0, false},
+		{"M", 0, false},
+		{"-1G", 0, false},
+		{"2X", 0, false},
+		{"lots", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1.0K"},
+		{1536, "1.5K"},
+		{500 << 20, "500.0M"},
+		{3 << 29, "1.5G"},
+	}
+	for _, tt := range tests {
+		if got := FormatSize(tt.in); got != tt.want {
+			t.Errorf("FormatSize(%d) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPutStoresContentOnce(t *testing.T) {
+	folder := t.TempDir()
+	s := Open(filepath.Join(folder, ".originals"), Retention{})
+	a, b := filepath.Join(folder, "a.png"), filepath.Join(folder, "b.png")
+	writeFile(t, a, "same", time.Now())
+	writeFile(t, b, "same", time.Now())
+
+	va, err := s.Put(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vb, err := s.Put(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if va.Hash != vb.Hash || va.Size != 4 {
+		t.Errorf("versions = %+v, %+v", va, vb)
+	}
+	objects, _ := filepath.Glob(filepath.Join(s.dir, "objects", "*", "*"))
+	versions, _ := s.Versions()
+	if len(objects) != 1 || len(versions) != 2 {
+		t.Errorf("%d objects for %d versions, want 1 for 2", len(objects), len(versions))
+	}
+	if data, err := os.ReadFile(s.ObjectPath(va.Hash)); err != nil || string(data) != "same" {
+		t.Errorf("stored content = %q, %v", data, err)
+	}
+}
+
+func TestPutAfterCutOffLine(t *testing.T) {
+	folder := t.TempDir()
+	s := Open(filepath.Join(folder, ".originals"), Retention{})
+	a := filepath.Join(folder, "a.png")
+	writeFile(t, a, "first", time.Now())
+	if _, err := s.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := os.OpenFile(filepath.Join(s.dir, "index.jsonl"), os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"name":"b.png","hash":"ab`)
+	f.Close()
+
+	writeFile(t, a, "second", time.Now())
+	v, err := s.Put(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions, _ := s.Versions()
+	if len(versions) != 2 {
+		t.Fatalf("versions = %v, want both copies of a.png", names(versions))
+	}
+	if data, err := os.ReadFile(s.ObjectPath(v.Hash)); err != nil || string(data) != "second" {
+		t.Errorf("latest content = %q, %v", data, err)
+	}
+}
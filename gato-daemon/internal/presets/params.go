@@ -0,0 +1,233 @@
+package presets
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Param types
+const (
+	TypeInt    = "int"
+	TypeString = "string"
+	TypeEnum   = "enum"
+)
+
+// Param is a typed value a preset command expects, referenced as {name}
+type Param struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Default string   `json:"default,omitempty"`
+	Min     *int     `json:"min,omitempty"`
+	Max     *int     `json:"max,omitempty"`
+	Choices []string `json:"choices,omitempty"`
+}
+
+var paramNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ParseParam parses a declaration such as "percent:int=50",
+// "quality:int 1..100=75" or "format:enum(webp|png|jpg)=webp"
+func ParseParam(decl string) (Param, error) {
+	var p Param
+
+	spec := decl
+	if i := strings.Index(spec, "="); i >= 0 {
+		p.Default = spec[i+1:]
+		spec = spec[:i]
+	}
+
+	name, typ, ok := strings.Cut(spec, ":")
+	if !ok {
+		typ = TypeString
+	}
+	p.Name = strings.TrimSpace(name)
+	typ = strings.TrimSpace(typ)
+
+	switch {
+	case strings.HasPrefix(typ, TypeEnum):
+		p.Type = TypeEnum
+		rest := strings.TrimPrefix(typ, TypeEnum)
+		if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+			return p, fmt.Errorf("invalid param %q: enum needs choices, e.g. format:enum(webp|png)", decl)
+		}
+		for _, c := range strings.Split(rest[1:len(rest)-1], "|") {
+			if c = strings.TrimSpace(c); c != "" {
+				p.Choices = append(p.Choices, c)
+			}
+		}
+	case strings.HasPrefix(typ, TypeInt):
+		p.Type = TypeInt
+		if rng := strings.TrimSpace(strings.TrimPrefix(typ, TypeInt)); rng != "" {
+			lo, hi, ok := strings.Cut(rng, "..")
+			if !ok {
+				return p, fmt.Errorf("invalid param %q: range must look like 1..100", decl)
+			}
+			if lo != "" {
+				n, err := strconv.Atoi(lo)
+				if err != nil {
+					return p, fmt.Errorf("invalid param %q: bad minimum %q", decl, lo)
+				}
+				p.Min = &n
+			}
+			if hi != "" {
+				n, err := strconv.Atoi(hi)
+				if err != nil {
+					return p, fmt.Errorf("invalid param %q: bad maximum %q", decl, hi)
+				}
+				p.Max = &n
+			}
+		}
+	case typ == TypeString:
+		p.Type = TypeString
+	default:
+		return p, fmt.Errorf("invalid param %q: unknown type %q (int, string, enum)", decl, typ)
+	}
+
+	return p, p.Validate()
+}
+
+// String formats the param back into declaration syntax
+func (p Param) String() string {
+	s := p.Name + ":" + p.Type
+	switch p.Type {
+	case TypeEnum:
+		s += "(" + strings.Join(p.Choices, "|") + ")"
+	case TypeInt:
+		if p.Min != nil || p.Max != nil {
+			s += " "
+			if p.Min != nil {
+				s += strconv.Itoa(*p.Min)
+			}
+			s += ".."
+			if p.Max != nil {
+				s += strconv.Itoa(*p.Max)
+			}
+		}
+	}
+	if p.Default != "" {
+		s += "=" + p.Default
+	}
+	return s
+}
+
+// Validate checks the declaration itself, including its default value
+func (p Param) Validate() error {
+	if !paramNameRe.MatchString(p.Name) {
+		return fmt.Errorf("invalid param name %q", p.Name)
+	}
+	for _, reserved := range Placeholders {
+		if "{"+p.Name+"}" == reserved {
+			return fmt.Errorf("param name %q is reserved", p.Name)
+		}
+	}
+	switch p.Type {
+	case TypeInt, TypeString:
+	case TypeEnum:
+		if len(p.Choices) == 0 {
+			return fmt.Errorf("param %s: enum needs at least one choice", p.Name)
+		}
+	default:
+		return fmt.Errorf("param %s: unknown type %q", p.Name, p.Type)
+	}
+	if p.Min != nil && p.Max != nil && *p.Min > *p.Max {
+		return fmt.Errorf("param %s: minimum is greater than maximum", p.Name)
+	}
+	if p.Default != "" {
+		if err := p.Check(p.Default); err != nil {
+			return fmt.Errorf("default: %w", err)
+		}
+	}
+	return nil
+}
+
+// Check validates a value against the param's type
+func (p Param) Check(value string) error {
+	switch p.Type {
+	case TypeInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer, got %q", p.Name, value)
+		}
+		if p.Min != nil && n < *p.Min {
+			return fmt.Errorf("%s must be at least %d", p.Name, *p.Min)
+		}
+		if p.Max != nil && n > *p.Max {
+			return fmt.Errorf("%s must be at most %d", p.Name, *p.Max)
+		}
+	case TypeEnum:
+		for _, c := range p.Choices {
+			if value == c {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of %s", p.Name, strings.Join(p.Choices, ", "))
+	case TypeString:
+		// Values end up in a shell command; keep them to a single safe word
+		if value == "" || strings.ContainsAny(value, " \t\n'\"`$;&|<>(){}\\") {
+			return fmt.Errorf("%s contains characters that are not allowed: %q", p.Name, value)
+		}
+	}
+	return nil
+}
+
+// ParseValues turns key=value assignments into a map
+func ParseValues(assignments []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, a := range assignments {
+		k, v, ok := strings.Cut(a, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid value %q, expected key=value", a)
+		}
+		values[k] = v
+	}
+	return values, nil
+}
+
+// Expand fills in the preset's params, returning a command ready for a folder
+func (p Preset) Expand(values map[string]string) (string, error) {
+	for k := range values {
+		if _, ok := p.Param(k); !ok {
+			return "", fmt.Errorf("preset %s has no param %q", p.Name, k)
+		}
+	}
+
+	command := p.Command
+	for _, param := range p.Params {
+		value, ok := values[param.Name]
+		if !ok {
+			if param.Default == "" {
+				return "", fmt.Errorf("preset %s needs a value for %s (--set %s=...)", p.Name, param.Name, param.Name)
+			}
+			value = param.Default
+		}
+		if err := param.Check(value); err != nil {
+			return "", err
+		}
+		command = strings.ReplaceAll(command, "{"+param.Name+"}", value)
+	}
+	return command, nil
+}
+
+// Param looks up a param by name
+func (p Preset) Param(name string) (Param, bool) {
+	for _, param := range p.Params {
+		if param.Name == name {
+			return param, true
+		}
+	}
+	return Param{}, false
+}
+
+// mustParams parses builtin declarations
+func mustParams(decls ...string) []Param {
+	params := make([]Param, len(decls))
+	for i, d := range decls {
+		p, err := ParseParam(d)
+		if err != nil {
+			panic(err)
+		}
+		params[i] = p
+	}
+	return params
+}
@@ -0,0 +1,147 @@
+package presets
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseParam(t *testing.T) {
+	tests := []struct {
+		decl    string
+		want    string // formatted back with String, "" if an error is expected
+		errText string
+	}{
+		{"percent:int=50", "percent:int=50", ""},
+		{"quality:int 1..100=75", "quality:int 1..100=75", ""},
+		{"quality:int 1..", "quality:int 1..", ""},
+		{"quality:int ..9", "quality:int ..9", ""},
+		{"format:enum(webp| png |jpg)=png", "format:enum(webp|png|jpg)=png", ""},
+		{"size", "size:string", ""},
+		{"size:string=256x256", "size:string=256x256", ""},
+		{"quality:int 1-100", "", "range must look like 1..100"},
+		{"quality:int a..9", "", "bad minimum"},
+		{"quality:int 1..b", "", "bad maximum"},
+		{"quality:int 100..1", "", "minimum is greater than maximum"},
+		{"quality:int 1..100=500", "", "at most 100"},
+		{"percent:int=half", "", "must be an integer"},
+		{"format:enum", "", "enum needs choices"},
+		{"format:enum()", "", "at least one choice"},
+		{"format:enum(webp|png)=gif", "", "must be one of webp, png"},
+		{"size:float", "", "unknown type"},
+		{"Size:int", "", "invalid param name"},
+		{"2x:int", "", "invalid param name"},
+		{"name:string", "", "reserved"},
+		{"dir", "", "reserved"},
+	}
+	for _, tt := range tests {
+		p, err := ParseParam(tt.decl)
+		if tt.errText != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("ParseParam(%q) error = %v, want %q", tt.decl, err, tt.errText)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseParam(%q): %v", tt.decl, err)
+			continue
+		}
+		if got := p.String(); got != tt.want {
+			t.Errorf("ParseParam(%q) = %s, want %s", tt.decl, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	quality := mustParams("quality:int 1..100")[0]
+	percent := mustParams("percent:int")[0]
+	format := mustParams("format:enum(webp|png)")[0]
+	size := mustParams("size:string")[0]
+
+	tests := []struct {
+		param Param
+		value string
+		ok    bool
+	}{
+		{quality, "1", true},
+		{quality, "100", true},
+		{quality, "0", false},
+		{quality, "101", false},
+		{quality, "-5", false},
+		{quality, "5.5", false},
+		{percent, "-5", true},
+		{percent, "", false},
+		{format, "png", true},
+		{format, "PNG", false},
+		{format, "", false},
+		{size, "256x256", true},
+		{size, "50%", true},
+		{size, "a-b_c.d", true},
+		{size, "", false},
+		{size, "256 256", false},
+		{size, "x;rm -rf ~", false},
+		{size, "$(id)", false},
+		{size, "`id`", false},
+		{size, "a|b", false},
+		{size, "a>b", false},
+		{size, "it's", false},
+		{size, `a\b`, false},
+		{size, "{}", false},
+	}
+	for _, tt := range tests {
+		if err := tt.param.Check(tt.value); (err == nil) != tt.ok {
+			t.Errorf("%s.Check(%q) = %v, want ok %v", tt.param, tt.value, err, tt.ok)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	p := Preset{
+		Name:    "thumb",
+		Command: "convert {} -resize {size} -quality {quality} {dir}/{name}.{format}",
+		Params:  mustParams("size:string=256x256", "quality:int 1..100=80", "format:enum(webp|png)"),
+	}
+	tests := []struct {
+		values  map[string]string
+		want    string
+		errText string
+	}{
+		{map[string]string{"format": "png"}, "convert {} -resize 256x256 -quality 80 {dir}/{name}.png", ""},
+		{map[string]string{"format": "webp", "quality": "5", "size": "10%"}, "convert {} -resize 10% -quality 5 {dir}/{name}.webp", ""},
+		{nil, "", "needs a value for format"},
+		{map[string]string{"format": "gif"}, "", "must be one of"},
+		{map[string]string{"format": "png", "quality": "0"}, "", "at least 1"},
+		{map[string]string{"format": "png", "colour": "red"}, "", `no param "colour"`},
+	}
+	for _, tt := range tests {
+		got, err := p.Expand(tt.values)
+		if tt.errText != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expand(%v) error = %v, want %q", tt.values, err, tt.errText)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Expand(%v) = %q, %v; want %q", tt.values, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	values, err := ParseValues([]string{"percent=33", "size=1=2", "empty="})
+	if err != nil || values["percent"] != "33" || values["size"] != "1=2" || values["empty"] != "" || len(values) != 3 {
+		t.Errorf("ParseValues = %v, %v", values, err)
+	}
+	for _, bad := range []string{"percent", "=33"} {
+		if _, err := ParseValues([]string{bad}); err == nil {
+			t.Errorf("ParseValues(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestBuiltinsAreValid(t *testing.T) {
+	for _, p := range Builtins() {
+		if err := ValidateCommand(p.Command, p.Params); err != nil {
+			t.Errorf("%s: %v", p.Name, err)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package presets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/veinticinco/gato-daemon/internal/fsutil"
+)
+
+// Preset is a named command template
+type Preset struct {
+	Name        string  `json:"name"`
+	Command     string  `json:"command"`
+	Description string  `json:"description,omitempty"`
+	Params      []Param `json:"params,omitempty"`
+	Builtin     bool    `json:"builtin"`
+}
+
+// Builtin presets, in display order
+var builtins = []Preset{
+	{Name: "compress", Command: "convert {} -strip -quality {quality} {}", Description: "Compress images",
+		Params: mustParams("quality:int 1..100=75")},
+	{Name: "webp", Command: "convert {} -quality {quality} {dir}/{name}.webp && gato trash {}", Description: "Convert to WebP",
+		Params: mustParams("quality:int 1..100=90")},
+	{Name: "png", Command: "convert {} {dir}/{name}.png && gato trash {}", Description: "Convert to PNG"},
+	{Name: "jpg", Command: "convert {} -quality {quality} {dir}/{name}.jpg && gato trash {}", Description: "Convert to JPG",
+		Params: mustParams("quality:int 1..100=85")},
+	{Name: "optimize", Command: "pngquant --force --quality=65-80 --output {} {}", Description: "Optimize PNG with pngquant"},
+	{Name: "resize", Command: "convert {} -resize {percent}% {}", Description: "Resize images",
+		Params: mustParams("percent:int 1..1000=50")},
+	{Name: "mp4", Command: "ffmpeg -i {} -c:v libx264 -preset {speed} -c:a aac -y {dir}/{name}.mp4 && gato trash {}", Description: "Convert video to MP4",
+		Params: mustParams("speed:enum(ultrafast|fast|medium|slow|veryslow)=medium")},
+	{Name: "mp3", Command: "ffmpeg -i {} -c:a libmp3lame -q:a 2 -y {dir}/{name}.mp3 && gato trash {}", Description: "Convert audio to MP3"},
+	{Name: "gif", Command: "ffmpeg -i {} -vf 'fps={fps},scale={width}:-1' -y {dir}/{name}.gif && gato trash {}", Description: "Convert video to GIF",
+		Params: mustParams("fps:int 1..60=10", "width:int 16..4096=480")},
+}
+
+// aliases keep the old fixed-value preset names working
+var aliases = map[string]struct {
+	preset string
+	values map[string]string
+}{
+	"resize-50": {"resize", map[string]string{"percent": "50"}},
+	"resize-25": {"resize", map[string]string{"percent": "25"}},
+}
+
+// Placeholders accepted in commands
+var Placeholders = []string{"{}", "{name}", "{ext}", "{dir}"}
+
+// Store manages user presets, shared with Gato Carpetas through presets.json
+type Store struct {
+	path string
+	user map[string]userPreset
+}
+
+// file mirrors the JSON layout written by the GUI
+type file struct {
+	Presets map[string]userPreset `json:"presets"`
+}
+
+// userPreset is stored as a plain command string, or as an object
+// when it declares params
+type userPreset struct {
+	Command string  `json:"command"`
+	Params  []Param `json:"params,omitempty"`
+}
+
+func (u *userPreset) UnmarshalJSON(data []byte) error {
+	var command string
+	if err := json.Unmarshal(data, &command); err == nil {
+		u.Command = command
+		return nil
+	}
+	type plain userPreset
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+func (u userPreset) MarshalJSON() ([]byte, error) {
+	if len(u.Params) == 0 {
+		return json.Marshal(u.Command)
+	}
+	type plain userPreset
+	return json.Marshal(plain(u))
+}
+
+// New creates a preset store backed by ~/.config/gato/presets.json
+func New() *Store {
+	homeDir, _ := os.UserHomeDir()
+	return &Store{
+		path: filepath.Join(homeDir, ".config", "gato", "presets.json"),
+		user: make(map[string]userPreset),
+	}
+}
+
+// Load reads user presets; a missing file means no user presets
+func (s *Store) Load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("invalid %s: %w", s.path, err)
+	}
+	if f.Presets != nil {
+		s.user = f.Presets
+	}
+	return nil
+}
+
+// Save writes user presets in the same format as the GUI
+func (s *Store) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(file{Presets: s.user}, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The GUI and the CLI share the file; never leave it half written
+	return fsutil.WriteFile(s.path, data, 0644)
+}
+
+// Get looks up a preset by name, builtins first. Aliases resolve to their
+// preset with the alias values as defaults.
+func (s *Store) Get(name string) (Preset, bool) {
+	if alias, ok := aliases[name]; ok {
+		p, _ := s.Get(alias.preset)
+		params := make([]Param, len(p.Params))
+		for i, param := range p.Params {
+			if v, ok := alias.values[param.Name]; ok {
+				param.Default = v
+			}
+			params[i] = param
+		}
+		p.Name = name
+		p.Params = params
+		return p, true
+	}
+	for _, p := range builtins {
+		if p.Name == name {
+			p.Builtin = true
+			return p, true
+		}
+	}
+	if u, ok := s.user[name]; ok {
+		return Preset{Name: name, Command: u.Command, Params: u.Params}, true
+	}
+	return Preset{}, false
+}
+
+// Builtins returns the builtin presets in display order
+func Builtins() []Preset {
+	list := make([]Preset, len(builtins))
+	for i, p := range builtins {
+		p.Builtin = true
+		list[i] = p
+	}
+	return list
+}
+
+// User returns user presets sorted by name
+func (s *Store) User() []Preset {
+	var list []Preset
+	for name, u := range s.user {
+		list = append(list, Preset{Name: name, Command: u.Command, Params: u.Params})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// List returns builtins followed by user presets
+func (s *Store) List() []Preset {
+	return append(Builtins(), s.User()...)
+}
+
+// Set creates or replaces a user preset after validating its command
+func (s *Store) Set(name, command string, params []Param) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("preset name cannot be empty")
+	}
+	for _, p := range builtins {
+		if p.Name == name {
+			return fmt.Errorf("%s is a builtin preset", name)
+		}
+	}
+	if _, ok := aliases[name]; ok {
+		return fmt.Errorf("%s is a builtin preset", name)
+	}
+	if err := ValidateCommand(command, params); err != nil {
+		return err
+	}
+	s.user[name] = userPreset{Command: command, Params: params}
+	return s.Save()
+}
+
+// Exists reports whether a user preset with this name exists
+func (s *Store) Exists(name string) bool {
+	_, ok := s.user[name]
+	return ok
+}
+
+// Delete removes a user preset
+func (s *Store) Delete(name string) error {
+	if _, ok := s.user[name]; !ok {
+		if _, builtin := s.Get(name); builtin {
+			return fmt.Errorf("%s is a builtin preset and cannot be deleted", name)
+		}
+		return fmt.Errorf("preset not found: %s", name)
+	}
+	delete(s.user, name)
+	return s.Save()
+}
+
+var placeholderRe = regexp.MustCompile(`\$?\{[^{}\s]*\}`)
+
+// ValidatePlaceholders checks that a command only uses known placeholders
+// and references the file at least once. Shell expansions like ${VAR} are ignored.
+func ValidatePlaceholders(command string) error {
+	return ValidateCommand(command, nil)
+}
+
+// ValidateCommand is ValidatePlaceholders for a command that also references
+// the given params. Every declared param must be used.
+func ValidateCommand(command string, params []Param) error {
+	if strings.TrimSpace(command) == "" {
+		return fmt.Errorf("command cannot be empty")
+	}
+
+	declared := make(map[string]bool)
+	for _, p := range params {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		if declared["{"+p.Name+"}"] {
+			return fmt.Errorf("param %s declared twice", p.Name)
+		}
+		declared["{"+p.Name+"}"] = true
+	}
+
+	usesFile := false
+	used := make(map[string]bool)
+	for _, token := range placeholderRe.FindAllString(command, -1) {
+		if strings.HasPrefix(token, "$") {
+			continue
+		}
+		if declared[token] {
+			used[token] = true
+			continue
+		}
+		known := false
+		for _, p := range Placeholders {
+			if token == p {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown placeholder %s (available: %s)", token, strings.Join(Placeholders, " "))
+		}
+		if token == "{}" || token == "{name}" {
+			usesFile = true
+		}
+	}
+	if !usesFile {
+		return fmt.Errorf("command never references the file ({} or {name})")
+	}
+	for token := range declared {
+		if !used[token] {
+			return fmt.Errorf("param %s is declared but never used", strings.Trim(token, "{}"))
+		}
+	}
+	return nil
+}
+
+// Shell builtins and keywords that never need a binary on PATH
+var shellBuiltins = map[string]bool{
+	"cd": true, "echo": true, "test": true, "[": true, "[[": true, "true": true,
+	"false": true, "exit": true, "export": true, "set": true, "if": true,
+	"then": true, "else": true, "fi": true, "for": true, "do": true, "done": true,
+	"printf": true, "read": true, "local": true, ":": true,
+}
+
+var separatorRe = regexp.MustCompile(`&&|\|\||[;|]`)
+
+// Binaries returns the programs a command invokes, in order of appearance
+func Binaries(command string) []string {
+	seen := make(map[string]bool)
+	var bins []string
+	for _, segment := range separatorRe.Split(command, -1) {
+		for _, word := range strings.Fields(segment) {
+			word = strings.Trim(word, `'"()`)
+			// Skip leading VAR=value assignments
+			if strings.Contains(word, "=") && !strings.HasPrefix(word, "=") {
+				continue
+			}
+			if word != "" && !shellBuiltins[word] && !seen[word] {
+				seen[word] = true
+				bins = append(bins, word)
+			}
+			break
+		}
+	}
+	return bins
+}
+
+// MissingBinaries returns the programs used by a command that are not on PATH
+func MissingBinaries(command string) []string {
+	var missing []string
+	for _, bin := range Binaries(command) {
+		if strings.ContainsAny(bin, "{}") {
+			continue
+		}
+		if _, err := exec.LookPath(bin); err != nil {
+			missing = append(missing, bin)
+		}
+	}
+	return missing
+}
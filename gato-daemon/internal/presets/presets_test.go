@@ -0,0 +1,127 @@
+package presets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		params  []string
+		errText string
+	}{
+		{"convert {} {dir}/{name}.webp", nil, ""},
+		{"ffmpeg -i {} {dir}/{name}{ext}.mp3", nil, ""},
+		{`echo "${HOME}" {}`, nil, ""},
+		{"convert {} -resize {percent}% {}", []string{"percent:int=50"}, ""},
+		{"  ", nil, "cannot be empty"},
+		{"convert in.png out.webp", nil, "never references the file"},
+		{"convert {dir}/x.png {ext}", nil, "never references the file"},
+		{"cat {file}", nil, "unknown placeholder {file}"},
+		{"convert {} -resize {percent}% {}", nil, "unknown placeholder {percent}"},
+		{"convert {} {}", []string{"percent:int=50"}, "declared but never used"},
+		{"convert {} {p} {}", []string{"p:int", "p:string"}, "declared twice"},
+	}
+	for _, tt := range tests {
+		params := mustParams(tt.params...)
+		err := ValidateCommand(tt.command, params)
+		if tt.errText == "" {
+			if err != nil {
+				t.Errorf("ValidateCommand(%q): %v", tt.command, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.errText) {
+			t.Errorf("ValidateCommand(%q) = %v, want %q", tt.command, err, tt.errText)
+		}
+	}
+
+	// Params edited in presets.json by hand are validated too
+	two := 2
+	bad := []Param{{Name: "p", Type: TypeInt, Max: &two, Default: "9"}}
+	if err := ValidateCommand("convert {} {p}", bad); err == nil || !strings.Contains(err.Error(), "at most 2") {
+		t.Errorf("ValidateCommand with a bad default = %v", err)
+	}
+}
+
+func TestBinaries(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"convert {} out.webp", "convert"},
+		{"cd {dir} && zip -r a.zip . ; echo done", "zip"},
+		{"LANG=C sort {} | uniq -c > {dir}/counts || true", "sort uniq"},
+		{"(ffmpeg -i {} a.mp3); ffmpeg -i {} b.mp3", "ffmpeg"},
+		{"'/usr/bin/convert' {} x", "/usr/bin/convert"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(Binaries(tt.command), " "); got != tt.want {
+			t.Errorf("Binaries(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".config", "gato", "presets.json")
+
+	// The GUI writes commands as plain strings
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte(`{"presets": {"gui": "gzip -k {}"}}`), 0644)
+
+	s := New()
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := s.Get("gui"); !ok || p.Command != "gzip -k {}" || p.Builtin {
+		t.Errorf("Get(gui) = %+v, %v", p, ok)
+	}
+	before, _ := os.Stat(path)
+	if err := s.Set("thumb", "convert {} -resize {size} {dir}/{name}_t{ext}", mustParams("size:string=256x256")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"webp", "", " "} {
+		if err := s.Set(name, "true {}", nil); err == nil {
+			t.Errorf("Set(%q) succeeded", name)
+		}
+	}
+	if err := s.Set("bad", "cat {file}", nil); err == nil {
+		t.Error("Set accepted an unknown placeholder")
+	}
+
+	s = New()
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	p, ok := s.Get("thumb")
+	if !ok || len(p.Params) != 1 || p.Params[0].String() != "size:string=256x256" {
+		t.Errorf("Get(thumb) after reload = %+v, %v", p, ok)
+	}
+	if _, ok := s.Get("bad"); ok {
+		t.Error("rejected preset was saved")
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"gui": "gzip -k {}"`) {
+		t.Errorf("plain presets not kept as strings:\n%s", data)
+	}
+
+	// Saved by replacing the file, so a reader never sees half of it
+	after, _ := os.Stat(path)
+	if os.SameFile(before, after) || after.Mode().Perm() != 0644 {
+		t.Errorf("presets.json rewritten in place, mode %v", after.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("files next to presets.json: %v", entries)
+	}
+
+	if err := s.Delete("gui"); err != nil || s.Exists("gui") {
+		t.Errorf("Delete(gui) = %v", err)
+	}
+	if err := s.Delete("webp"); err == nil {
+		t.Error("deleted a builtin")
+	}
+}
@@ -0,0 +1,351 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/veinticinco/gato-daemon/internal/fsutil"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeServerError    = -32000
+)
+
+// ErrRunning is returned by Listen when another daemon owns the socket
+var ErrRunning = errors.New("another gato-daemon is already running")
+
+// Request is a JSON-RPC request, one per line
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC response, one per line
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error object
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Handler runs a method. params is null when the request has none.
+type Handler func(params json.RawMessage) (any, error)
+
+// StreamHandler runs a subscription. After the request is answered it
+// sends notifications with send until ctx is done, which happens when the
+// client disconnects or the server stops.
+type StreamHandler func(ctx context.Context, params json.RawMessage, send func(params any) error) error
+
+// Notification is a request without an ID, used for subscription updates
+type Notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// SocketPath is $XDG_RUNTIME_DIR/gato/daemon.sock
+func SocketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "gato-"+strconv.Itoa(os.Getuid()))
+	}
+	return filepath.Join(dir, "gato", "daemon.sock")
+}
+
+// Server answers requests on a unix socket
+type Server struct {
+	ln       net.Listener
+	unlock   func()
+	ctx      context.Context
+	mu       sync.Mutex
+	handlers map[string]Handler
+	streams  map[string]StreamHandler
+}
+
+// Listen takes over the socket at path. A lock on path.lock, held until
+// Serve returns, keeps a second daemon out: if it is taken, ErrRunning is
+// returned; a socket left behind by a crashed daemon is replaced.
+func Listen(path string) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	unlock, err := fsutil.Lock(path+".lock", 0)
+	if errors.Is(err, fsutil.ErrLocked) {
+		return nil, ErrRunning
+	}
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		unlock()
+		return nil, err
+	}
+	return &Server{ln: ln, unlock: unlock, handlers: make(map[string]Handler), streams: make(map[string]StreamHandler)}, nil
+}
+
+// Handle registers a method
+func (s *Server) Handle(method string, h Handler) {
+	s.mu.Lock()
+	s.handlers[method] = h
+	s.mu.Unlock()
+}
+
+// HandleStream registers a subscription method. Its notifications use the
+// method's name.
+func (s *Server) HandleStream(method string, h StreamHandler) {
+	s.mu.Lock()
+	s.streams[method] = h
+	s.mu.Unlock()
+}
+
+// Serve accepts connections until ctx is done, then removes the socket
+// and releases the lock
+func (s *Server) Serve(ctx context.Context) {
+	s.ctx = ctx
+	defer s.unlock()
+	go func() {
+		<-ctx.Done()
+		s.ln.Close()
+	}()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	enc := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			enc.Encode(Response{JSONRPC: "2.0", ID: json.RawMessage("null"),
+				Error: &Error{Code: CodeParseError, Message: err.Error()}})
+			continue
+		}
+
+		s.mu.Lock()
+		stream, ok := s.streams[req.Method]
+		s.mu.Unlock()
+		if ok {
+			s.serveStream(conn, scanner, enc, req, stream)
+			return
+		}
+
+		resp := s.call(req)
+		// Requests without an ID are notifications and get no answer
+		if len(req.ID) == 0 {
+			continue
+		}
+		resp.ID = req.ID
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// serveStream answers a subscription and then hands the connection to it
+// until either side closes
+func (s *Server) serveStream(conn net.Conn, scanner *bufio.Scanner, enc *json.Encoder, req Request, h StreamHandler) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	go func() {
+		// Anything the client sends now is ignored; EOF means it left
+		for scanner.Scan() {
+		}
+		cancel()
+	}()
+
+	if len(req.ID) > 0 {
+		if err := enc.Encode(Response{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage("null")}); err != nil {
+			return
+		}
+	}
+	send := func(params any) error {
+		return enc.Encode(Notification{JSONRPC: "2.0", Method: req.Method, Params: params})
+	}
+	if err := h(ctx, req.Params, send); err != nil && ctx.Err() == nil && len(req.ID) > 0 {
+		enc.Encode(Response{JSONRPC: "2.0", ID: req.ID, Error: asError(err)})
+	}
+}
+
+func (s *Server) call(req Request) Response {
+	resp := Response{JSONRPC: "2.0"}
+
+	s.mu.Lock()
+	h, ok := s.handlers[req.Method]
+	s.mu.Unlock()
+	if !ok {
+		resp.Error = &Error{Code: CodeMethodNotFound, Message: "unknown method: " + req.Method}
+		return resp
+	}
+
+	result, err := h(req.Params)
+	if err != nil {
+		resp.Error = asError(err)
+		return resp
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		resp.Error = &Error{Code: CodeServerError, Message: err.Error()}
+		return resp
+	}
+	resp.Result = data
+	return resp
+}
+
+// asError keeps the code of an *Error and reports anything else as a
+// server error
+func asError(err error) *Error {
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) {
+		rpcErr = &Error{Code: CodeServerError, Message: err.Error()}
+	}
+	return rpcErr
+}
+
+// Params decodes a request's params into v, reporting bad input as
+// invalid params
+func Params(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		return &Error{Code: CodeInvalidParams, Message: "missing params"}
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// Client calls methods on a running daemon
+type Client struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	nextID  int
+}
+
+// Dial connects to the daemon's socket
+func Dial(path string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	return &Client{conn: conn, scanner: scanner}, nil
+}
+
+// Close disconnects
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Call runs method and decodes its result into result, which may be nil
+func (c *Client) Call(method string, params, result any) error {
+	c.nextID++
+	req := Request{JSONRPC: "2.0", ID: json.RawMessage(strconv.Itoa(c.nextID)), Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = data
+	}
+	if err := json.NewEncoder(c.conn).Encode(req); err != nil {
+		return err
+	}
+	return c.wait(req.ID, result)
+}
+
+// wait reads until the response to id arrives
+func (c *Client) wait(id json.RawMessage, result any) error {
+	for c.scanner.Scan() {
+		var resp Response
+		if err := json.Unmarshal(c.scanner.Bytes(), &resp); err != nil {
+			return err
+		}
+		if string(resp.ID) != string(id) {
+			continue
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	}
+	if err := c.scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("daemon closed the connection")
+}
+
+// Subscribe calls a subscription method and passes each notification's
+// params to fn until the connection closes or fn returns an error
+func (c *Client) Subscribe(method string, params any, fn func(params json.RawMessage) error) error {
+	if err := c.Call(method, params, nil); err != nil {
+		return err
+	}
+	for c.scanner.Scan() {
+		var n struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Error  *Error          `json:"error"`
+		}
+		if err := json.Unmarshal(c.scanner.Bytes(), &n); err != nil {
+			return err
+		}
+		if n.Error != nil {
+			return n.Error
+		}
+		if n.Method != method {
+			continue
+		}
+		if err := fn(n.Params); err != nil {
+			return err
+		}
+	}
+	if err := c.scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("daemon closed the connection")
+}
@@ -0,0 +1,176 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// serve starts a server on a temporary socket until the test ends
+func serve(t *testing.T, setup func(s *Server)) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "daemon.sock")
+	s, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup(s)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Serve(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return path
+}
+
+func dial(t *testing.T, path string) *Client {
+	t.Helper()
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestListenSingleInstance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.sock")
+	// A socket file left behind by a crashed daemon is replaced
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(path); !errors.Is(err, ErrRunning) {
+		t.Fatalf("second Listen: %v, want ErrRunning", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Serve(ctx)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket left after Serve: %v", err)
+	}
+	s, err = Listen(path)
+	if err != nil {
+		t.Fatalf("Listen after Serve returned: %v", err)
+	}
+	s.Serve(ctx)
+}
+
+func TestCall(t *testing.T) {
+	path := serve(t, func(s *Server) {
+		s.Handle("add", func(params json.RawMessage) (any, error) {
+			var args struct{ A, B int }
+			if err := Params(params, &args); err != nil {
+				return nil, err
+			}
+			return args.A + args.B, nil
+		})
+		s.Handle("fail", func(json.RawMessage) (any, error) {
+			return nil, errors.New("boom")
+		})
+	})
+	c := dial(t, path)
+
+	tests := []struct {
+		method string
+		params any
+		want   int
+		code   int
+	}{
+		{"add", map[string]int{"a": 2, "b": 3}, 5, 0},
+		{"add", nil, 0, CodeInvalidParams},
+		{"add", "not an object", 0, CodeInvalidParams},
+		{"fail", nil, 0, CodeServerError},
+		{"missing", nil, 0, CodeMethodNotFound},
+		{"add", map[string]int{"a": 1}, 1, 0},
+	}
+	for _, tt := range tests {
+		var got int
+		err := c.Call(tt.method, tt.params, &got)
+		var rpcErr *Error
+		switch {
+		case tt.code == 0 && err != nil:
+			t.Errorf("%s(%v): %v", tt.method, tt.params, err)
+		case tt.code != 0 && (!errors.As(err, &rpcErr) || rpcErr.Code != tt.code):
+			t.Errorf("%s(%v) error = %v, want code %d", tt.method, tt.params, err, tt.code)
+		case got != tt.want:
+			t.Errorf("%s(%v) = %d, want %d", tt.method, tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	left := make(chan struct{})
+	path := serve(t, func(s *Server) {
+		s.HandleStream("count", func(ctx context.Context, params json.RawMessage, send func(any) error) error {
+			var n int
+			if err := Params(params, &n); err != nil {
+				return err
+			}
+			for i := 1; i <= n; i++ {
+				if err := send(i); err != nil {
+					return err
+				}
+			}
+			<-ctx.Done()
+			close(left)
+			return nil
+		})
+	})
+
+	var got []int
+	err := dial(t, path).Subscribe("count", 3, func(params json.RawMessage) error {
+		var i int
+		json.Unmarshal(params, &i)
+		got = append(got, i)
+		if len(got) == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop || len(got) != 3 || got[2] != 3 {
+		t.Fatalf("Subscribe = %v after %v", err, got)
+	}
+
+	// Bad params are answered with an error instead of a stream
+	var rpcErr *Error
+	err = dial(t, path).Subscribe("count", nil, func(json.RawMessage) error { return nil })
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeInvalidParams {
+		t.Errorf("Subscribe without params = %v", err)
+	}
+}
+
+func TestSubscriptionEndsWhenClientLeaves(t *testing.T) {
+	left := make(chan struct{})
+	path := serve(t, func(s *Server) {
+		s.HandleStream("wait", func(ctx context.Context, _ json.RawMessage, send func(any) error) error {
+			send("ready")
+			<-ctx.Done()
+			close(left)
+			return nil
+		})
+	})
+	c := dial(t, path)
+	c.Subscribe("wait", nil, func(json.RawMessage) error { return errStop })
+	c.Close()
+	select {
+	case <-left:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream handler still running after the client left")
+	}
+}
+
+var errStop = errors.New("stop")
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/veinticinco/gato-daemon/internal/folders"
+)
+
+// Names the daemon is reachable under on the session bus
+const (
+	BusName    = "org.gato.Daemon1"
+	ObjectPath = dbus.ObjectPath("/org/gato/Daemon1")
+	Interface  = "org.gato.Daemon1"
+)
+
+// Action is a folder action as a D-Bus struct (ssssasbsb)
+type Action struct {
+	ID           string
+	Path         string
+	Action       string
+	Command      string
+	Extensions   []string
+	KeepOriginal bool
+	NotifyLevel  string
+	Enabled      bool
+}
+
+// Job is a queued or running job as a D-Bus struct (sssssx)
+type Job struct {
+	ID     string
+	File   string
+	Folder string
+	State  string
+	Action string
+	Queued int64 // unix seconds
+}
+
+// Service exports a folders.Manager on the bus
+type Service struct {
+	mgr   *folders.Manager
+	conn  *dbus.Conn
+	props *prop.Properties
+	stop  func()
+	owned bool // conn was opened by Connect and is closed with the service
+}
+
+// Connect opens a private session bus connection, so the service does not
+// depend on the notifier's, exports mgr on it and claims BusName
+func Connect(mgr *folders.Manager, version string) (*Service, error) {
+	conn, err := dbus.SessionBusPrivate()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to session bus: %w", err)
+	}
+	if err := conn.Auth(nil); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot authenticate to session bus: %w", err)
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot register on session bus: %w", err)
+	}
+	s, err := Export(conn, mgr, version)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s.owned = true
+	if err := s.RequestName(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Export publishes the org.gato.Daemon1 interface on conn. It does not
+// claim the bus name, see RequestName.
+func Export(conn *dbus.Conn, mgr *folders.Manager, version string) (*Service, error) {
+	s := &Service{mgr: mgr, conn: conn}
+
+	if err := conn.Export(s, ObjectPath, Interface); err != nil {
+		return nil, err
+	}
+
+	props, err := prop.Export(conn, ObjectPath, prop.Map{
+		Interface: {
+			"Version": {Value: version, Emit: prop.EmitConst},
+			"Started": {Value: time.Now().Unix(), Emit: prop.EmitConst},
+			"Jobs":    {Value: uint32(0), Emit: prop.EmitTrue},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.props = props
+
+	node := &introspect.Node{
+		Name: string(ObjectPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       Interface,
+				Methods:    methods,
+				Signals:    signals,
+				Properties: props.Introspection(Interface),
+			},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), ObjectPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return nil, err
+	}
+
+	events, stop := mgr.Subscribe()
+	s.stop = stop
+	go s.forward(events)
+	return s, nil
+}
+
+// RequestName claims BusName, failing if another daemon owns it
+func (s *Service) RequestName() error {
+	reply, err := s.conn.RequestName(BusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("%s is already owned", BusName)
+	}
+	return nil
+}
+
+// Close stops emitting signals and unexports the object
+func (s *Service) Close() {
+	s.stop()
+	s.conn.Export(nil, ObjectPath, Interface)
+	s.conn.ReleaseName(BusName)
+	if s.owned {
+		s.conn.Close()
+	}
+}
+
+func in(name, typ string) introspect.Arg {
+	return introspect.Arg{Name: name, Type: typ, Direction: "in"}
+}
+func out(name, typ string) introspect.Arg {
+	return introspect.Arg{Name: name, Type: typ, Direction: "out"}
+}
+
+// methods describes the exported methods with argument names, which
+// introspect.Methods cannot recover by reflection
+var methods = []introspect.Method{
+	{Name: "ListFolders", Args: []introspect.Arg{out("paths", "as")}},
+	{Name: "ListActions", Args: []introspect.Arg{in("path", "s"), out("actions", "a(ssssasbsb)")}},
+	{Name: "AddAction", Args: []introspect.Arg{in("path", "s"), in("action", "s"), in("command", "s"),
+		in("extensions", "as"), in("keep_original", "b"), in("notify_level", "s"), out("id", "s")}},
+	{Name: "RemoveAction", Args: []introspect.Arg{in("id", "s")}},
+	{Name: "SetActionEnabled", Args: []introspect.Arg{in("id", "s"), in("enabled", "b")}},
+	{Name: "PauseFolder", Args: []introspect.Arg{in("path", "s"), in("seconds", "u")}},
+	{Name: "ResumeFolder", Args: []introspect.Arg{in("path", "s")}},
+	{Name: "Enqueue", Args: []introspect.Arg{in("file", "s"), out("id", "s")}},
+	{Name: "ListJobs", Args: []introspect.Arg{out("jobs", "a(sssssx)")}},
+	{Name: "CancelJob", Args: []introspect.Arg{in("id", "s")}},
+	{Name: "CancelFolder", Args: []introspect.Arg{in("path", "s"), out("ids", "as")}},
+	{Name: "Reload"},
+}
+
+var signals = []introspect.Signal{
+	{Name: "JobQueued", Args: []introspect.Arg{
+		{Name: "id", Type: "s"}, {Name: "file", Type: "s"}}},
+	{Name: "JobStarted", Args: []introspect.Arg{
+		{Name: "id", Type: "s"}, {Name: "file", Type: "s"}, {Name: "action", Type: "s"}}},
+	{Name: "JobProgress", Args: []introspect.Arg{
+		{Name: "id", Type: "s"}, {Name: "progress", Type: "d"}}},
+	{Name: "JobFinished", Args: []introspect.Arg{
+		{Name: "id", Type: "s"}, {Name: "file", Type: "s"}, {Name: "status", Type: "s"}, {Name: "error", Type: "s"}}},
+	{Name: "ConfigChanged"},
+	{Name: "WatchError", Args: []introspect.Arg{
+		{Name: "path", Type: "s"}, {Name: "error", Type: "s"}}},
+}
+
+// forward turns manager events into signals and property changes
+func (s *Service) forward(events <-chan folders.Event) {
+	for e := range events {
+		switch e.Kind {
+		case folders.EventJobQueued:
+			s.emit("JobQueued", e.Job.ID, e.Job.File)
+		case folders.EventJobStarted:
+			s.emit("JobStarted", e.Job.ID, e.Job.File, e.Action)
+		case folders.EventJobProgress:
+			s.emit("JobProgress", e.Job.ID, e.Job.Progress)
+		case folders.EventJobFinished:
+			s.emit("JobFinished", e.Job.ID, e.Job.File, e.Status, e.Error)
+		case folders.EventConfigChanged:
+			s.emit("ConfigChanged")
+		case folders.EventWatchError:
+			s.emit("WatchError", e.Path, e.Error)
+		default:
+			continue
+		}
+		if e.Job != nil && e.Kind != folders.EventJobProgress {
+			s.props.SetMust(Interface, "Jobs", uint32(len(s.mgr.Jobs())))
+		}
+	}
+}
+
+func (s *Service) emit(name string, args ...any) {
+	s.conn.Emit(ObjectPath, Interface+"."+name, args...)
+}
+
+// dbusError wraps a manager error for the caller
+func dbusError(err error) *dbus.Error {
+	if err == nil {
+		return nil
+	}
+	return dbus.NewError(Interface+".Error", []any{err.Error()})
+}
+
+// ListFolders returns the configured folder paths
+func (s *Service) ListFolders() ([]string, *dbus.Error) {
+	var paths []string
+	err := s.mgr.Inspect(func() { paths = s.mgr.ListUniqueFolders() })
+	if paths == nil {
+		paths = []string{}
+	}
+	return paths, dbusError(err)
+}
+
+// ListActions returns a folder's actions, or every action if path is empty
+func (s *Service) ListActions(path string) ([]Action, *dbus.Error) {
+	actions := []Action{}
+	err := s.mgr.Inspect(func() {
+		list := s.mgr.ListFolders()
+		if path != "" {
+			list = s.mgr.GetFolderActions(path)
+		}
+		for _, a := range list {
+			actions = append(actions, toAction(a))
+		}
+	})
+	return actions, dbusError(err)
+}
+
+// AddAction adds a predefined action or a command to a folder and returns its ID
+func (s *Service) AddAction(path, action, command string, extensions []string, keepOriginal bool, notifyLevel string) (string, *dbus.Error) {
+	if (action == "") == (command == "") {
+		return "", dbusError(fmt.Errorf("give either an action or a command"))
+	}
+	if action != "" && !folders.IsPredefinedAction(action) {
+		return "", dbusError(fmt.Errorf("unknown action: %s", action))
+	}
+
+	var added folders.FolderAction
+	err := s.mgr.Apply(func() error {
+		var err error
+		added, err = s.mgr.AddFolder(path, action, command, extensions, keepOriginal, notifyLevel)
+		return err
+	})
+	return added.ID, dbusError(err)
+}
+
+// RemoveAction removes an action by ID or unique prefix
+func (s *Service) RemoveAction(id string) *dbus.Error {
+	return dbusError(s.mgr.Apply(func() error {
+		_, err := s.mgr.RemoveActionByID(id)
+		return err
+	}))
+}
+
+// SetActionEnabled turns an action on or off
+func (s *Service) SetActionEnabled(id string, enabled bool) *dbus.Error {
+	return dbusError(s.mgr.Apply(func() error {
+		_, err := s.mgr.SetActionEnabled(id, enabled)
+		return err
+	}))
+}
+
+// PauseFolder stops processing a folder for seconds, or until resumed if 0
+func (s *Service) PauseFolder(path string, seconds uint32) *dbus.Error {
+	var until time.Time
+	if seconds > 0 {
+		until = time.Now().Add(time.Duration(seconds) * time.Second).Truncate(time.Second)
+	}
+	return dbusError(s.mgr.Apply(func() error { return s.mgr.PauseFolder(path, until) }))
+}
+
+// ResumeFolder resumes a paused folder
+func (s *Service) ResumeFolder(path string) *dbus.Error {
+	return dbusError(s.mgr.Apply(func() error { return s.mgr.ResumeFolder(path) }))
+}
+
+// Enqueue processes an existing file in a configured folder and returns the job ID
+func (s *Service) Enqueue(file string) (string, *dbus.Error) {
+	job, err := s.mgr.Enqueue(file)
+	return job.ID, dbusError(err)
+}
+
+// ListJobs returns queued and running jobs
+func (s *Service) ListJobs() ([]Job, *dbus.Error) {
+	jobs := []Job{}
+	for _, j := range s.mgr.Jobs() {
+		jobs = append(jobs, Job{ID: j.ID, File: j.File, Folder: j.Folder, State: j.State, Action: j.Action, Queued: j.Queued.Unix()})
+	}
+	return jobs, nil
+}
+
+// CancelJob stops a job by ID or unique prefix
+func (s *Service) CancelJob(id string) *dbus.Error {
+	_, err := s.mgr.CancelJob(id)
+	return dbusError(err)
+}
+
+// CancelFolder stops every job in a folder and returns their IDs
+func (s *Service) CancelFolder(path string) ([]string, *dbus.Error) {
+	jobs, err := s.mgr.CancelFolder(path)
+	ids := []string{}
+	for _, j := range jobs {
+		ids = append(ids, j.ID)
+	}
+	return ids, dbusError(err)
+}
+
+// Reload rereads folders.toml
+func (s *Service) Reload() *dbus.Error {
+	return dbusError(s.mgr.Reload())
+}
+
+func toAction(a folders.FolderAction) Action {
+	ext := a.Extensions
+	if ext == nil {
+		ext = []string{}
+	}
+	return Action{
+		ID:           a.ID,
+		Path:         a.Path,
+		Action:       a.Action,
+		Command:      a.Command,
+		Extensions:   ext,
+		KeepOriginal: a.KeepOriginal,
+		NotifyLevel:  a.NotifyMode(),
+		Enabled:      a.IsEnabled(),
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/veinticinco/gato-daemon/internal/dbustest"
+	"github.com/veinticinco/gato-daemon/internal/folders"
+)
+
+// setup runs a manager in a temporary home and exports it on a private bus
+func setup(t *testing.T) (dbus.BusObject, chan *dbus.Signal) {
+	t.Helper()
+	address := dbustest.Start(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", home+"/.local/state")
+	t.Setenv("XDG_DATA_HOME", home+"/.local/share")
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", address)
+
+	mgr := folders.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		mgr.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	svc, err := Connect(mgr, "1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(svc.Close)
+
+	client := dbustest.Connect(t, address)
+	if err := client.AddMatchSignal(dbus.WithMatchObjectPath(ObjectPath)); err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan *dbus.Signal, 64)
+	client.Signal(signals)
+	return client.Object(BusName, ObjectPath), signals
+}
+
+// waitSignal returns the first signal named name whose arguments start
+// with args, skipping others
+func waitSignal(t *testing.T, signals chan *dbus.Signal, name string, args ...any) *dbus.Signal {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case s := <-signals:
+			if s.Name != name || len(s.Body) < len(args) {
+				continue
+			}
+			match := true
+			for i, a := range args {
+				match = match && s.Body[i] == a
+			}
+			if match {
+				return s
+			}
+		case <-timeout:
+			t.Fatalf("no %s signal for %v", name, args)
+			return nil
+		}
+	}
+}
+
+func TestConnectOwnsName(t *testing.T) {
+	setup(t)
+	if _, err := Connect(folders.New(), "1.2.3"); err == nil {
+		t.Fatal("second service claimed the bus name")
+	}
+}
+
+func TestMethods(t *testing.T) {
+	obj, signals := setup(t)
+	folder := t.TempDir()
+
+	var version string
+	if err := obj.StoreProperty(Interface+".Version", &version); err != nil || version != "1.2.3" {
+		t.Errorf("Version = %q, %v", version, err)
+	}
+
+	var id string
+	if err := obj.Call(Interface+".AddAction", 0, folder, "", "true {}", []string{"txt"}, false, "").Store(&id); err != nil {
+		t.Fatal(err)
+	}
+	waitSignal(t, signals, Interface+".ConfigChanged")
+
+	var paths []string
+	if err := obj.Call(Interface+".ListFolders", 0).Store(&paths); err != nil || len(paths) != 1 || paths[0] != folder {
+		t.Errorf("ListFolders = %v, %v", paths, err)
+	}
+
+	var actions []Action
+	if err := obj.Call(Interface+".SetActionEnabled", 0, id[:6], false).Err; err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.Call(Interface+".ListActions", 0, folder).Store(&actions); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].ID != id || actions[0].Command != "true {}" || actions[0].Enabled {
+		t.Errorf("ListActions = %+v", actions)
+	}
+	obj.Call(Interface+".SetActionEnabled", 0, id, true)
+
+	for _, call := range []*dbus.Call{
+		obj.Call(Interface+".PauseFolder", 0, folder, uint32(60)),
+		obj.Call(Interface+".ResumeFolder", 0, folder),
+		obj.Call(Interface+".Reload", 0),
+	} {
+		if call.Err != nil {
+			t.Errorf("%s: %v", call.Method, call.Err)
+		}
+	}
+
+	errors := []struct {
+		method string
+		args   []any
+	}{
+		{"AddAction", []any{folder, "compress", "true", []string{}, false, ""}},
+		{"AddAction", []any{folder, "no-such-action", "", []string{}, false, ""}},
+		{"RemoveAction", []any{"ffffffff"}},
+		{"PauseFolder", []any{"/no/such/folder", uint32(0)}},
+		{"Enqueue", []any{"/no/such/file.txt"}},
+		{"CancelJob", []any{"ffffffff"}},
+	}
+	for _, tt := range errors {
+		call := obj.Call(Interface+"."+tt.method, 0, tt.args...)
+		if e, ok := call.Err.(dbus.Error); !ok || e.Name != Interface+".Error" {
+			t.Errorf("%s%v error = %v", tt.method, tt.args, call.Err)
+		}
+	}
+
+	if err := obj.Call(Interface+".RemoveAction", 0, id).Err; err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.Call(Interface+".ListFolders", 0).Store(&paths); err != nil || len(paths) != 0 {
+		t.Errorf("ListFolders after RemoveAction = %v, %v", paths, err)
+	}
+}
+
+func TestJobSignals(t *testing.T) {
+	obj, signals := setup(t)
+	folder := t.TempDir()
+	file := filepath.Join(folder, "a.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.Call(Interface+".AddAction", 0, folder, "", "true {}", []string{"txt"}, false, "").Err; err != nil {
+		t.Fatal(err)
+	}
+
+	var id string
+	if err := obj.Call(Interface+".Enqueue", 0, file).Store(&id); err != nil {
+		t.Fatal(err)
+	}
+	waitSignal(t, signals, Interface+".JobQueued", id, file)
+	jobs := waitSignal(t, signals, "org.freedesktop.DBus.Properties.PropertiesChanged", Interface)
+	if _, ok := jobs.Body[1].(map[string]dbus.Variant)["Jobs"]; !ok {
+		t.Errorf("PropertiesChanged without Jobs: %v", jobs.Body)
+	}
+	waitSignal(t, signals, Interface+".JobStarted", id, file)
+	finished := waitSignal(t, signals, Interface+".JobFinished", id, file)
+	if status := finished.Body[2]; status != "ok" {
+		t.Errorf("JobFinished status = %v, error = %v", status, finished.Body[3])
+	}
+
+	var listed []Job
+	if err := obj.Call(Interface+".ListJobs", 0).Store(&listed); err != nil || len(listed) != 0 {
+		t.Errorf("ListJobs after the job finished = %+v, %v", listed, err)
+	}
+	for {
+		changed := waitSignal(t, signals, "org.freedesktop.DBus.Properties.PropertiesChanged", Interface)
+		if count := changed.Body[1].(map[string]dbus.Variant)["Jobs"]; count.Value() == uint32(0) {
+			break
+		}
+	}
+	var count uint32
+	if err := obj.StoreProperty(Interface+".Jobs", &count); err != nil || count != 0 {
+		t.Errorf("Jobs = %d, %v", count, err)
+	}
+}
@@ -0,0 +1,227 @@
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Put moves path to the trash following the freedesktop.org Trash spec, so
+// file managers can list and restore it, and returns its new location. Files
+// on the home partition go to the home trash; files on other mounts go to
+// that mount's .Trash/$uid or .Trash-$uid directory.
+func Put(path string) (string, error) {
+	return PutIn(path, homeTrash())
+}
+
+// PutIn is Put with home used as the home trash instead of
+// $XDG_DATA_HOME/Trash
+func PutIn(path, home string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	dev := device(info)
+
+	if err := os.MkdirAll(home, 0700); err != nil {
+		return "", err
+	}
+	if homeInfo, err := os.Stat(home); err == nil && device(homeInfo) == dev {
+		return move(path, home, path)
+	}
+
+	top, err := mountPoint(path, dev)
+	if err != nil {
+		return "", err
+	}
+	dir, err := topdirTrash(top)
+	if err != nil {
+		return "", fmt.Errorf("no trash on %s: %w", top, err)
+	}
+	rel, err := filepath.Rel(top, path)
+	if err != nil {
+		return "", err
+	}
+	return move(path, dir, rel)
+}
+
+// Restore moves a file that Put returned as trashed back to path and
+// removes its .trashinfo. It fails rather than overwrite an existing file.
+func Restore(trashed, path string) error {
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	if err := os.Rename(trashed, path); err != nil {
+		return err
+	}
+	trashDir := filepath.Dir(filepath.Dir(trashed))
+	os.Remove(filepath.Join(trashDir, "info", filepath.Base(trashed)+".trashinfo"))
+	return nil
+}
+
+// ReportEnv names the environment variable that points gato trash, run by
+// an action, at a file where it records where each file went
+const ReportEnv = "GATO_TRASH_REPORT"
+
+// Report records that path was moved to trashed in the file named by
+// $GATO_TRASH_REPORT. Without the variable it does nothing.
+func Report(path, trashed string) error {
+	report := os.Getenv(ReportEnv)
+	if report == "" {
+		return nil
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(report, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	// NUL can't appear in paths, unlike newlines
+	_, err = f.WriteString(path + "\x00" + trashed + "\x00")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadReport returns where each file recorded in a report went
+func ReadReport(report string) (map[string]string, error) {
+	data, err := os.ReadFile(report)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(string(data), "\x00")
+	trashed := make(map[string]string)
+	for i := 0; i+1 < len(fields); i += 2 {
+		trashed[fields[i]] = fields[i+1]
+	}
+	return trashed, nil
+}
+
+// homeTrash is $XDG_DATA_HOME/Trash
+func homeTrash() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		dataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash")
+}
+
+// topdirTrash returns the trash directory for a mount, preferring the shared
+// $topdir/.Trash/$uid when the administrator set it up correctly
+func topdirTrash(top string) (string, error) {
+	uid := strconv.Itoa(os.Getuid())
+
+	shared := filepath.Join(top, ".Trash")
+	if info, err := os.Lstat(shared); err == nil &&
+		info.IsDir() && info.Mode()&os.ModeSymlink == 0 && info.Mode()&os.ModeSticky != 0 {
+		dir := filepath.Join(shared, uid)
+		if err := os.MkdirAll(dir, 0700); err == nil {
+			return dir, nil
+		}
+	}
+
+	dir := filepath.Join(top, ".Trash-"+uid)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		return "", errors.New(dir + " is not a directory")
+	}
+	return dir, nil
+}
+
+// move writes the .trashinfo file, reserving a unique name, and renames
+// path into the trash. infoPath is the path recorded in the info file.
+func move(path, trashDir, infoPath string) (string, error) {
+	filesDir := filepath.Join(trashDir, "files")
+	infoDir := filepath.Join(trashDir, "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", err
+		}
+	}
+
+	escaped := (&url.URL{Path: infoPath}).EscapedPath()
+	contents := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		escaped, time.Now().Format("2006-01-02T15:04:05"))
+
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s.%d%s", stem, n, ext)
+		}
+
+		// Creating the info file exclusively is what reserves the name
+		infoFile := filepath.Join(infoDir, name+".trashinfo")
+		f, err := os.OpenFile(infoFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.WriteString(contents)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(infoFile)
+			return "", err
+		}
+
+		dest := filepath.Join(filesDir, name)
+		if _, err := os.Lstat(dest); err == nil {
+			// Orphaned file without info; keep looking
+			os.Remove(infoFile)
+			continue
+		}
+		if err := os.Rename(path, dest); err != nil {
+			os.Remove(infoFile)
+			return "", err
+		}
+		return dest, nil
+	}
+}
+
+// mountPoint walks up from path while the parent is on the same device
+func mountPoint(path string, dev uint64) (string, error) {
+	dir := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+		info, err := os.Stat(parent)
+		if err != nil {
+			return "", err
+		}
+		if device(info) != dev {
+			return dir, nil
+		}
+		dir = parent
+	}
+}
+
+func device(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev)
+	}
+	return 0
+}
@@ -0,0 +1,284 @@
+package trash
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readInfo returns the Path and DeletionDate of a trashed file
+func readInfo(t *testing.T, trashed string) (string, time.Time) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(filepath.Dir(trashed)), "info", filepath.Base(trashed)+".trashinfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || lines[0] != "[Trash Info]" {
+		t.Fatalf("trashinfo:\n%s", data)
+	}
+	date, err := time.ParseInLocation("2006-01-02T15:04:05", strings.TrimPrefix(lines[2], "DeletionDate="), time.Local)
+	if err != nil {
+		t.Errorf("DeletionDate: %v", err)
+	}
+	return strings.TrimPrefix(lines[1], "Path="), date
+}
+
+func TestPutIn(t *testing.T) {
+	home := filepath.Join(t.TempDir(), "Trash")
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		escaped string // the name as written in Path=
+	}{
+		{"shot.png", "shot.png"},
+		{"my shot.png", "my%20shot.png"},
+		{"100%.png", "100%25.png"},
+		{"año #1.png", "a%C3%B1o%20%231.png"},
+		{"a?b.png", "a%3Fb.png"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		os.WriteFile(path, []byte(tt.name), 0644)
+		start := time.Now().Truncate(time.Second)
+
+		trashed, err := PutIn(path, home)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(home, "files", tt.name); trashed != want {
+			t.Errorf("PutIn(%s) = %s, want %s", tt.name, trashed, want)
+		}
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still in place", tt.name)
+		}
+		if data, _ := os.ReadFile(trashed); string(data) != tt.name {
+			t.Errorf("%s: trashed content %q", tt.name, data)
+		}
+		got, date := readInfo(t, trashed)
+		if want := dir + "/" + tt.escaped; got != want {
+			t.Errorf("%s: Path=%s, want %s", tt.name, got, want)
+		}
+		if date.Before(start) || date.After(time.Now()) {
+			t.Errorf("%s: DeletionDate=%v", tt.name, date)
+		}
+	}
+
+	if _, err := PutIn(filepath.Join(dir, "missing.png"), home); !os.IsNotExist(err) {
+		t.Errorf("PutIn of a missing file = %v", err)
+	}
+}
+
+func TestPutUsesDataHome(t *testing.T) {
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	path := filepath.Join(t.TempDir(), "shot.png")
+	os.WriteFile(path, nil, 0644)
+
+	trashed, err := Put(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dataHome, "Trash", "files", "shot.png"); trashed != want {
+		t.Errorf("Put = %s, want %s", trashed, want)
+	}
+	if info, err := os.Stat(filepath.Join(dataHome, "Trash")); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("trash directory: %v, %v", info.Mode(), err)
+	}
+}
+
+// TestCollisions trashes files with the same name from different folders
+func TestCollisions(t *testing.T) {
+	home := filepath.Join(t.TempDir(), "Trash")
+
+	// A leftover info file and an orphaned file both hold a name
+	os.MkdirAll(filepath.Join(home, "info"), 0700)
+	os.MkdirAll(filepath.Join(home, "files"), 0700)
+	os.WriteFile(filepath.Join(home, "info", "shot.2.png.trashinfo"), nil, 0600)
+	os.WriteFile(filepath.Join(home, "files", "shot.3.png"), nil, 0600)
+
+	want := []string{"shot.png", "shot.4.png", "shot.5.png"}
+	for i, name := range want {
+		path := filepath.Join(t.TempDir(), "shot.png")
+		os.WriteFile(path, []byte(strconv.Itoa(i)), 0644)
+		trashed, err := PutIn(path, home)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(trashed) != name {
+			t.Errorf("trash %d named %s, want %s", i, filepath.Base(trashed), name)
+		}
+		if data, _ := os.ReadFile(trashed); string(data) != strconv.Itoa(i) {
+			t.Errorf("%s holds %q", trashed, data)
+		}
+		if got, _ := readInfo(t, trashed); got != path {
+			t.Errorf("%s: Path=%s, want %s", name, got, path)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(home, "info", "shot.3.png.trashinfo")); !os.IsNotExist(err) {
+		t.Error("info for the orphaned file's name left behind")
+	}
+}
+
+func TestTopdirTrash(t *testing.T) {
+	uid := strconv.Itoa(os.Getuid())
+	tests := []struct {
+		name  string
+		setup func(top string)
+		want  string // relative to top, "" if an error is expected
+	}{
+		{"no shared trash", func(string) {}, ".Trash-" + uid},
+		{"shared trash", func(top string) {
+			os.Mkdir(filepath.Join(top, ".Trash"), 0777)
+			os.Chmod(filepath.Join(top, ".Trash"), 0777|os.ModeSticky)
+		}, ".Trash/" + uid},
+		{"shared trash without sticky bit", func(top string) {
+			os.Mkdir(filepath.Join(top, ".Trash"), 0777)
+		}, ".Trash-" + uid},
+		{"shared trash is a symlink", func(top string) {
+			target := filepath.Join(top, "elsewhere")
+			os.Mkdir(target, 0777)
+			os.Chmod(target, 0777|os.ModeSticky)
+			os.Symlink(target, filepath.Join(top, ".Trash"))
+		}, ".Trash-" + uid},
+		{"shared trash is a file", func(top string) {
+			os.WriteFile(filepath.Join(top, ".Trash"), nil, 0644)
+		}, ".Trash-" + uid},
+		{"user trash is a file", func(top string) {
+			os.WriteFile(filepath.Join(top, ".Trash-"+uid), nil, 0644)
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			top := t.TempDir()
+			tt.setup(top)
+			dir, err := topdirTrash(top)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("topdirTrash = %s, want an error", dir)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(top, tt.want); dir != want {
+				t.Errorf("topdirTrash = %s, want %s", dir, want)
+			}
+			if info, err := os.Lstat(dir); err != nil || info.Mode().Perm() != 0700 {
+				t.Errorf("%s: %v, %v", dir, info.Mode(), err)
+			}
+		})
+	}
+}
+
+// TestMoveToTopdir trashes a file into a mount's trash, whose info files
+// record paths relative to the mount
+func TestMoveToTopdir(t *testing.T) {
+	top := t.TempDir()
+	path := filepath.Join(top, "Photos", "my shot.png")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, nil, 0644)
+
+	dir, err := topdirTrash(top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trashed, err := move(path, dir, "Photos/my shot.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := readInfo(t, trashed); got != "Photos/my%20shot.png" {
+		t.Errorf("Path=%s, want a path relative to the mount", got)
+	}
+
+	// The mount point is the topmost folder on the file's device
+	info, _ := os.Stat(top)
+	mp, err := mountPoint(path, device(info))
+	if err != nil || !strings.HasPrefix(top, mp) {
+		t.Errorf("mountPoint(%s) = %s, %v", path, mp, err)
+	}
+	if parent, err := os.Stat(filepath.Dir(mp)); mp != "/" && (err != nil || device(parent) == device(info)) {
+		t.Errorf("mountPoint(%s) = %s, whose parent is on the same device", path, mp)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	home := filepath.Join(t.TempDir(), "Trash")
+	path := filepath.Join(t.TempDir(), "shot.png")
+	os.WriteFile(path, []byte("original"), 0644)
+	trashed, err := PutIn(path, home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := filepath.Join(home, "info", "shot.png.trashinfo")
+
+	// A new file with the name is never overwritten
+	os.WriteFile(path, []byte("new"), 0644)
+	if err := Restore(trashed, path); err == nil {
+		t.Error("Restore overwrote a file")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("file changed to %q", data)
+	}
+	if _, err := os.Stat(info); err != nil {
+		t.Errorf("info removed after a failed restore: %v", err)
+	}
+	os.Remove(path)
+
+	if err := Restore(trashed, path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "original" {
+		t.Errorf("restored %q", data)
+	}
+	if _, err := os.Lstat(info); !os.IsNotExist(err) {
+		t.Errorf("info left behind: %v", err)
+	}
+	if err := Restore(trashed, path+".again"); !os.IsNotExist(err) {
+		t.Errorf("Restore of an emptied trash entry = %v", err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "report")
+	t.Setenv(ReportEnv, "")
+	if err := Report("/a/shot.png", "/trash/files/shot.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(report); !os.IsNotExist(err) {
+		t.Error("report written without " + ReportEnv)
+	}
+
+	t.Setenv(ReportEnv, report)
+	cwd, _ := os.Getwd()
+	tests := []struct {
+		path, trashed string
+		want          string // path as recorded
+	}{
+		{"/a/shot.png", "/trash/files/shot.png", "/a/shot.png"},
+		{"/a/line\nbreak.png", "/trash/files/line\nbreak.png", "/a/line\nbreak.png"},
+		{"relative.png", "/trash/files/relative.png", filepath.Join(cwd, "relative.png")},
+	}
+	for _, tt := range tests {
+		if err := Report(tt.path, tt.trashed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := ReadReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(tests) {
+		t.Errorf("ReadReport = %q, want %d entries", got, len(tests))
+	}
+	for _, tt := range tests {
+		if got[tt.want] != tt.trashed {
+			t.Errorf("%q trashed at %q, want %q", tt.want, got[tt.want], tt.trashed)
+		}
+	}
+}